@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ncsnw/skavo/pkg/agent"
+	"github.com/ncsnw/skavo/pkg/goroutines"
+	"github.com/ncsnw/skavo/pkg/prompt"
+)
+
+//skavo analyze [snapshot.json [later.json]]: group the goroutines by what they're blocked on and point out deadlocks
+//and leaks, in two samples of the process -interval apart or in snapshots skavo goroutines wrote
+func analyze() {
+	var samples []*goroutines.Snapshot
+	if len(args) > 0 {
+		if len(args) > 2 {
+			panic("usage: skavo analyze [flags] [snapshot.json [later snapshot.json]]")
+		}
+		for _, path := range args {
+			s, err := goroutines.ReadJSON(path)
+			if err != nil {
+				panic(err)
+			}
+			samples = append(samples, s)
+		}
+	} else {
+		t, client, pod := selectTarget()
+		skavoAgent := agent.New(t)
+		process := prompt.SelectProcess(skavoAgent.ListProcesses(), *processFilter)
+		pd := newPodDelve(t, skavoAgent, process, client, pod, *containerName)
+		count := 2
+		if *interval <= 0 {
+			count = 1
+		}
+		samples = pd.Snapshots(count, *interval, &goroutines.AddressLoadConfig)
+		skavoAgent.Close()
+	}
+	var previous *goroutines.Snapshot
+	if len(samples) > 1 {
+		previous = samples[0]
+	}
+	fmt.Println()
+	goroutines.PrintAnalysis(os.Stdout, previous, samples[len(samples)-1])
+}
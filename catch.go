@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/ncsnw/skavo/pkg/agent"
+	"github.com/ncsnw/skavo/pkg/delve"
+	"github.com/ncsnw/skavo/pkg/prompt"
+)
+
+//skavo catch: keep the process running until it panics or hits a fatal error, then write what it looked like
+func catch() {
+	switch *onCrash {
+	case delve.OnCrashCrash, delve.OnCrashDetach:
+	default:
+		panic(fmt.Errorf("unknown -on-crash %s, expected crash or detach", *onCrash))
+	}
+	t, client, pod := selectTarget()
+	skavoAgent := agent.New(t)
+	defer skavoAgent.Close()
+	process := prompt.SelectProcess(skavoAgent.ListProcesses(), *processFilter)
+	pd := newPodDelve(t, skavoAgent, process, client, pod, *containerName)
+	report := pd.CatchCrash(*isRestart, *onCrash)
+	if report == nil {
+		fmt.Println("Stopped before the process crashed")
+		return
+	}
+	out := *snapshotOut
+	if out == "" {
+		out = fmt.Sprintf("crash.%d.json", report.Pid)
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	if err := ioutil.WriteFile(out, data, 0644); err != nil {
+		panic(fmt.Errorf("failed to write %s: %+v", out, err))
+	}
+	fmt.Printf("\n%s in goroutine %d: %s\n", report.Reason, report.Goroutine, report.Value)
+	for _, f := range report.Stack {
+		fmt.Printf("    %s\n        %s:%d\n", f.Function, f.File, f.Line)
+	}
+	fmt.Printf("\nWrote the report with %d goroutines to %s\n", len(report.Goroutines.Goroutines), out)
+}
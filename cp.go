@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+//skavo cp <container path> [local path]: copy a file or directory out of the container, without needing kubectl
+func cp() {
+	if len(args) < 1 || len(args) > 2 {
+		panic("usage: skavo cp [flags] <container path> [local path]")
+	}
+	src := args[0]
+	dest := path.Base(path.Clean(src))
+	if len(args) == 2 {
+		dest = args[1]
+		//like cp, copying to a directory puts the file in it
+		if stat, err := os.Stat(dest); err == nil && stat.IsDir() {
+			dest = filepath.Join(dest, path.Base(path.Clean(src)))
+		}
+	}
+	t, _, _ := selectTarget()
+	t.CopyFrom(src, dest)
+	fmt.Printf("Copied %s to %s\n", src, dest)
+}
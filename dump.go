@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/ncsnw/skavo/pkg/agent"
+	"github.com/ncsnw/skavo/pkg/prompt"
+)
+
+//skavo dump: write a core of the process without killing it, copy it here and debug it with dlv core
+func dump() {
+	t, client, pod := selectTarget()
+	skavoAgent := agent.New(t)
+	process := prompt.SelectProcess(skavoAgent.ListProcesses(), *processFilter)
+	pd := newPodDelve(t, skavoAgent, process, client, pod, *containerName)
+	core, exe := pd.Dump(*dumpDir)
+	skavoAgent.Close()
+	profile.PrintSourceMappings()
+	dlv, err := exec.LookPath("dlv")
+	if err != nil || !isTerminal(os.Stdin) {
+		fmt.Printf("Debug the core with: dlv core %s %s\n", exe, core)
+		return
+	}
+	fmt.Printf("Running dlv core %s %s\n", exe, core)
+	cmd := exec.Command(dlv, "core", exe, core)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		panic(fmt.Errorf("dlv core failed: %+v", err))
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/ncsnw/skavo/pkg/agent"
+	"github.com/ncsnw/skavo/pkg/prompt"
+)
+
+//skavo eval <expression>...: stop the process, print what the expressions evaluate to, and let it run again
+func eval() {
+	if len(args) < 1 {
+		panic("usage: skavo eval [flags] <expression>...")
+	}
+	stdout := os.Stdout
+	if *evalJSON {
+		//the progress goes to stderr so the json can be piped
+		os.Stdout = os.Stderr
+	}
+	t, client, pod := selectTarget()
+	skavoAgent := agent.New(t)
+	process := prompt.SelectProcess(skavoAgent.ListProcesses(), *processFilter)
+	pd := newPodDelve(t, skavoAgent, process, client, pod, *containerName)
+	results := pd.Eval(args, *goroutineID, *frame)
+	skavoAgent.Close()
+	os.Stdout = stdout
+	failed := false
+	if *evalJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(results); err != nil {
+			panic(err)
+		}
+	}
+	for _, result := range results {
+		failed = failed || result.Err != ""
+		switch {
+		case *evalJSON:
+		case result.Err != "":
+			fmt.Fprintf(os.Stderr, "%s: %s\n", result.Expr, result.Err)
+		default:
+			fmt.Printf("%s = %s\n", result.Expr, result.Value)
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
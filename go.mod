@@ -1,13 +1,15 @@
 module github.com/ncsnw/skavo
 
-go 1.15
+go 1.16
 
 require (
 	github.com/AlecAivazis/survey/v2 v2.2.7
 	github.com/imdario/mergo v0.3.11 // indirect
+	github.com/mattn/go-isatty v0.0.8
 	github.com/narcolepticsnowman/go-mirror v0.0.1
 	github.com/spf13/cobra v1.1.1
 	golang.org/x/time v0.0.0-20201208040808-7e3f01d25324 // indirect
+	gopkg.in/yaml.v2 v2.3.0
 	k8s.io/api v0.20.4
 	k8s.io/apimachinery v0.20.4
 	k8s.io/cli-runtime v0.20.4
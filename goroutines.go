@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ncsnw/skavo/pkg/agent"
+	"github.com/ncsnw/skavo/pkg/dlv"
+	"github.com/ncsnw/skavo/pkg/prompt"
+)
+
+//skavo goroutines: stop the process briefly to read every goroutine, write them as json and print them grouped
+func snapshot() {
+	t, client, pod := selectTarget()
+	skavoAgent := agent.New(t)
+	process := prompt.SelectProcess(skavoAgent.ListProcesses(), *processFilter)
+	pd := newPodDelve(t, skavoAgent, process, client, pod, *containerName)
+	var cfg *dlv.LoadConfig
+	if *locals {
+		cfg = &dlv.DefaultLoadConfig
+	}
+	s := pd.Snapshot(cfg)
+	skavoAgent.Close()
+	out := *snapshotOut
+	if out == "" {
+		out = fmt.Sprintf("goroutines.%d.json", process.Pid)
+	}
+	if err := s.WriteJSON(out); err != nil {
+		panic(fmt.Errorf("failed to write %s: %+v", out, err))
+	}
+	s.PrintSummary(os.Stdout)
+	fmt.Printf("\nWrote the snapshot to %s\n", out)
+}
@@ -0,0 +1,263 @@
+package agent
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/ncsnw/skavo/pkg/agent/protocol"
+	"github.com/ncsnw/skavo/pkg/k8s"
+	"github.com/ncsnw/skavo/pkg/target"
+	"github.com/ncsnw/skavo/pkg/util"
+)
+
+//The skavo agent copied into a target. Requests are multiplexed over one exec session, which saves the api server and
+//kubelet round trip each exec costs.
+type Agent struct {
+	Target target.Target
+	//The agent binary in the target
+	Path string
+	//The agent wasn't in the target, it was copied in for this session
+	Copied bool
+
+	mu      sync.Mutex
+	in      *io.PipeWriter
+	nextID  int
+	pending map[int]*call
+	//Why the session ended, set once it has
+	err error
+}
+
+//A request waiting for its response
+type call struct {
+	log  io.Writer
+	done chan protocol.Response
+}
+
+//uname -m to go architectures
+var machineArches = map[string]string{
+	"x86_64":  "amd64",
+	"aarch64": "arm64",
+	"arm64":   "arm64",
+	"armv7l":  "arm",
+	"armv6l":  "arm",
+	"i686":    "386",
+	"i386":    "386",
+	"ppc64le": "ppc64le",
+	"s390x":   "s390x",
+}
+
+//Build the agent for the target's architecture and copy it in, unless this build is already there
+func New(t target.Target) *Agent {
+	out := new(bytes.Buffer)
+	errOut := new(bytes.Buffer)
+	if err := t.Exec([]string{"uname", "-m"}, k8s.ExecOptions{Out: out, ErrOut: errOut}); err != nil {
+		panic(fmt.Errorf("failed to read the architecture of %s: %s %+v", t, errOut, err))
+	}
+	machine := strings.TrimSpace(out.String())
+	arch, ok := machineArches[machine]
+	if !ok {
+		panic(fmt.Errorf("unsupported architecture %s", machine))
+	}
+	bin, err := Build(arch)
+	util.MaybePanic(err)
+	a := &Agent{Target: t, Path: protocol.Dir + "/" + filepath.Base(bin), pending: make(map[int]*call)}
+	if t.Exec([]string{"test", "-x", a.Path}) != nil {
+		fmt.Printf("Copying the skavo agent to %s\n", t)
+		util.MaybePanic(t.Exec([]string{"mkdir", "-p", protocol.Dir}))
+		t.CopyTo(bin, a.Path)
+		a.Copied = true
+	}
+	a.start()
+	a.verify(bin)
+	return a
+}
+
+//Start the exec session the requests are sent over
+func (a *Agent) start() {
+	in, stdin := io.Pipe()
+	stdout, out := io.Pipe()
+	a.in = stdin
+	go func() {
+		err := a.Target.Exec([]string{a.Path}, k8s.ExecOptions{In: in, Out: out, ErrOut: os.Stderr})
+		if err == nil {
+			err = fmt.Errorf("the skavo agent exited")
+		}
+		_ = out.CloseWithError(err)
+	}()
+	go a.read(stdout)
+}
+
+//Route the responses to the requests waiting for them
+func (a *Agent) read(stdout io.Reader) {
+	decoder := json.NewDecoder(stdout)
+	for {
+		var res protocol.Response
+		if err := decoder.Decode(&res); err != nil {
+			a.mu.Lock()
+			a.err = fmt.Errorf("lost the connection to the skavo agent: %+v", err)
+			for id, c := range a.pending {
+				c.done <- protocol.Response{ID: id, Error: a.err.Error()}
+			}
+			a.pending = nil
+			a.mu.Unlock()
+			return
+		}
+		a.mu.Lock()
+		c := a.pending[res.ID]
+		if c != nil && res.Log == "" {
+			delete(a.pending, res.ID)
+		}
+		a.mu.Unlock()
+		if c == nil {
+			continue
+		}
+		if res.Log != "" {
+			_, _ = io.WriteString(c.log, res.Log)
+			continue
+		}
+		c.done <- res
+	}
+}
+
+//End the session, the agent finishes the requests it has and exits
+func (a *Agent) Close() {
+	_ = a.in.Close()
+}
+
+//End the session and delete the agent from the target, and its directory if nothing else is in it. For agents copied
+//in just to look around, so they aren't left in every container that was looked at.
+func (a *Agent) Remove() {
+	a.Close()
+	errOut := new(bytes.Buffer)
+	if err := a.Target.Exec([]string{"rm", "-f", a.Path}, k8s.ExecOptions{ErrOut: errOut}); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to remove the skavo agent from %s: %s %+v\n", a.Target, errOut, err)
+		return
+	}
+	//fails when delve or other agents are installed there too
+	_ = a.Target.Exec([]string{"rmdir", protocol.Dir})
+}
+
+//Make sure the copy in the target is the agent that was built
+func (a *Agent) verify(bin string) {
+	data, err := ioutil.ReadFile(bin)
+	util.MaybePanic(err)
+	sum := sha256.Sum256(data)
+	caps := a.Capabilities()
+	if expected := hex.EncodeToString(sum[:]); caps.AgentSha256 != expected {
+		panic(fmt.Errorf("checksum of %s is %s, expected %s", a.Path, caps.AgentSha256, expected))
+	}
+}
+
+//Send a request to the agent and wait for the response, output of the request is written to log as it runs
+func (a *Agent) Call(method string, params interface{}, result interface{}, log io.Writer) error {
+	req := protocol.Request{Method: method}
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+		req.Params = data
+	}
+	c := &call{log: log, done: make(chan protocol.Response, 1)}
+	a.mu.Lock()
+	if a.err != nil {
+		a.mu.Unlock()
+		return a.err
+	}
+	a.nextID++
+	req.ID = a.nextID
+	a.pending[req.ID] = c
+	line, err := json.Marshal(req)
+	if err == nil {
+		//the pipe is written while holding the lock so lines don't interleave
+		_, err = a.in.Write(append(line, '\n'))
+	}
+	if err != nil {
+		delete(a.pending, req.ID)
+		a.mu.Unlock()
+		return fmt.Errorf("failed to send %s to the skavo agent: %+v", method, err)
+	}
+	a.mu.Unlock()
+	res := <-c.done
+	if res.Error != "" {
+		return fmt.Errorf("%s", res.Error)
+	}
+	if result != nil {
+		return json.Unmarshal(res.Result, result)
+	}
+	return nil
+}
+
+func (a *Agent) Capabilities() *protocol.Capabilities {
+	caps := &protocol.Capabilities{}
+	util.MaybePanic(a.Call(protocol.MethodCapabilities, nil, caps, os.Stderr))
+	return caps
+}
+
+//List the processes running in the target
+func (a *Agent) ListProcesses() []k8s.ContainerProcess {
+	var processes []protocol.Process
+	if err := a.Call(protocol.MethodProcesses, nil, &processes, os.Stderr); err != nil {
+		panic(fmt.Errorf("failed to list processes: %+v", err))
+	}
+	list := make([]k8s.ContainerProcess, len(processes))
+	for i, p := range processes {
+		list[i] = k8s.ContainerProcess(p)
+	}
+	return list
+}
+
+//Install delve, the install log is written to log as it runs
+func (a *Agent) InstallDelve(params protocol.InstallParams, log io.Writer) (string, error) {
+	result := &protocol.InstallResult{}
+	err := a.Call(protocol.MethodInstall, params, result, log)
+	return result.DlvPath, err
+}
+
+//Start delve under the agent's supervisor, returns once delve is listening or has exited
+func (a *Agent) Launch(params protocol.LaunchParams) (*protocol.Status, error) {
+	status := &protocol.Status{}
+	err := a.Call(protocol.MethodLaunch, params, status, os.Stderr)
+	return status, err
+}
+
+func (a *Agent) Status() (*protocol.Status, error) {
+	status := &protocol.Status{}
+	err := a.Call(protocol.MethodStatus, nil, status, os.Stderr)
+	return status, err
+}
+
+//Interrupt the delve the agent launched, which detaches it from the process it attached to
+func (a *Agent) StopDelve() (*protocol.Status, error) {
+	status := &protocol.Status{}
+	err := a.Call(protocol.MethodStop, nil, status, os.Stderr)
+	return status, err
+}
+
+//Run a command in the target
+func (a *Agent) Exec(command []string, stdin []byte) (*protocol.ExecResult, error) {
+	result := &protocol.ExecResult{}
+	err := a.Call(protocol.MethodExec, protocol.ExecParams{Command: command, Stdin: stdin}, result, os.Stderr)
+	return result, err
+}
+
+//Read length bytes of a file in the target from offset, fewer at the end of the file
+func (a *Agent) ReadFile(path string, offset int64, length int) ([]byte, error) {
+	var data []byte
+	err := a.Call(protocol.MethodRead, protocol.ReadParams{Path: path, Offset: offset, Length: length}, &data, os.Stderr)
+	return data, err
+}
+
+//Write a file in the target
+func (a *Agent) WriteFile(path string, data []byte, mode os.FileMode) error {
+	return a.Call(protocol.MethodWrite, protocol.WriteParams{Path: path, Data: data, Mode: uint32(mode)}, nil, os.Stderr)
+}
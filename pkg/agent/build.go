@@ -0,0 +1,123 @@
+package agent
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+)
+
+//The agent's source, skavo builds it for the target's architecture with the local go toolchain when there's no prebuilt
+//agent for it
+//
+//go:embed protocol/*.go skavo-agent/*.go skavo-agent/*.pem
+var source embed.FS
+
+//Agents built for the common architectures at release time, so skavo doesn't need go installed. Source builds only have
+//the README.
+//
+//go:generate env GOOS=linux GOARCH=amd64 CGO_ENABLED=0 go build -trimpath "-ldflags=-s -w" -o prebuilt/skavo-agent-amd64 ./skavo-agent
+//go:generate env GOOS=linux GOARCH=arm64 CGO_ENABLED=0 go build -trimpath "-ldflags=-s -w" -o prebuilt/skavo-agent-arm64 ./skavo-agent
+//go:embed prebuilt
+var prebuilt embed.FS
+
+//The module path the source is built under, so the agent's imports resolve
+const module = "github.com/ncsnw/skavo"
+
+//Builds are serialized so concurrent callers share the cached build
+var buildLock sync.Mutex
+
+//Write out the prebuilt agent for linux on arch, or build it and reuse the build cached for this source
+func Build(arch string) (string, error) {
+	buildLock.Lock()
+	defer buildLock.Unlock()
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	if data, err := prebuilt.ReadFile("prebuilt/skavo-agent-" + arch); err == nil {
+		sum := sha256.Sum256(data)
+		bin := filepath.Join(cache, "skavo", fmt.Sprintf("skavo-agent-%s-%s", hex.EncodeToString(sum[:])[:12], arch))
+		if _, err := os.Stat(bin); err == nil {
+			return bin, nil
+		}
+		if err := os.MkdirAll(filepath.Dir(bin), 0755); err != nil {
+			return "", err
+		}
+		return bin, ioutil.WriteFile(bin, data, 0755)
+	}
+	sum, err := sourceSha256()
+	if err != nil {
+		return "", err
+	}
+	bin := filepath.Join(cache, "skavo", fmt.Sprintf("skavo-agent-%s-%s", sum[:12], arch))
+	if _, err := os.Stat(bin); err == nil {
+		return bin, nil
+	}
+	fmt.Printf("Building the skavo agent for linux/%s\n", arch)
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		return "", fmt.Errorf("this skavo build has no prebuilt agent for linux/%s and go, which builds it, isn't on the PATH: %+v", arch, err)
+	}
+	dir, err := ioutil.TempDir("", "skavo-agent")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+	if err := writeSource(dir); err != nil {
+		return "", err
+	}
+	cmd := exec.Command(goBin, "build", "-trimpath", "-ldflags=-s -w", "-o", bin, "./pkg/agent/skavo-agent")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOOS=linux", "GOARCH="+arch, "CGO_ENABLED=0", "GOFLAGS=", "GOWORK=off")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("failed to build the skavo agent: %+v", err)
+	}
+	return bin, nil
+}
+
+func writeSource(dir string) error {
+	goMod := "module " + module + "\n\ngo 1.16\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte(goMod), 0644); err != nil {
+		return err
+	}
+	return fs.WalkDir(source, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := source.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		dest := filepath.Join(dir, "pkg", "agent", path)
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return err
+		}
+		return ioutil.WriteFile(dest, data, 0644)
+	})
+}
+
+func sourceSha256() (string, error) {
+	hash := sha256.New()
+	err := fs.WalkDir(source, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := source.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		hash.Write([]byte(path))
+		hash.Write(data)
+		return nil
+	})
+	return hex.EncodeToString(hash.Sum(nil)), err
+}
@@ -0,0 +1,48 @@
+package agent
+
+import (
+	"fmt"
+	"io"
+)
+
+const fileBlockSize = 64 * 1024
+
+//Reads a file in the target in blocks, so reading the elf headers only takes a few requests
+type File struct {
+	agent  *Agent
+	path   string
+	blocks map[int64][]byte
+}
+
+func (a *Agent) Open(path string) *File {
+	return &File{agent: a, path: path, blocks: make(map[int64][]byte)}
+}
+
+func (f *File) ReadAt(p []byte, off int64) (int, error) {
+	n := 0
+	for n < len(p) {
+		pos := off + int64(n)
+		block, err := f.block(pos / fileBlockSize)
+		if err != nil {
+			return n, err
+		}
+		start := pos % fileBlockSize
+		if start >= int64(len(block)) {
+			return n, io.EOF
+		}
+		n += copy(p[n:], block[start:])
+	}
+	return n, nil
+}
+
+func (f *File) block(i int64) ([]byte, error) {
+	if data, ok := f.blocks[i]; ok {
+		return data, nil
+	}
+	data, err := f.agent.ReadFile(f.path, i*fileBlockSize, fileBlockSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %+v", f.path, err)
+	}
+	f.blocks[i] = data
+	return data, nil
+}
@@ -0,0 +1,143 @@
+//The messages skavo and the in-container agent exchange. The agent reads one json Request per line on stdin and writes
+//json Responses, one per line, on stdout until stdin is closed. Requests are handled concurrently, so one exec session
+//can carry every request skavo makes, and responses are matched to requests by ID.
+//
+//This package is built into the agent, so it can only import the standard library.
+package protocol
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	MethodCapabilities = "capabilities"
+	MethodProcesses    = "processes"
+	MethodInstall      = "install"
+	MethodLaunch       = "launch"
+	MethodStatus       = "status"
+	MethodStop         = "stop"
+	MethodExec         = "exec"
+	MethodRead         = "read"
+	MethodWrite        = "write"
+)
+
+//Where the agent keeps its files in the target
+const Dir = "/tmp/skavo"
+
+//The install log, lines starting with StepMarker start a step
+const InstallLog = Dir + "/install.log"
+
+const StepMarker = "skavo-step: "
+
+type Request struct {
+	ID     int
+	Method string
+	Params json.RawMessage `json:",omitempty"`
+}
+
+//A response with Log set carries output of a request that is still running, the last response for a request doesn't
+type Response struct {
+	ID     int
+	Result json.RawMessage `json:",omitempty"`
+	Error  string          `json:",omitempty"`
+	Log    string          `json:",omitempty"`
+}
+
+type Capabilities struct {
+	//The go architecture of the target, like amd64
+	Arch string
+	Uid  int
+	//CAP_SYS_PTRACE is in the agent's effective capabilities
+	SysPtrace bool
+	//kernel.yama.ptrace_scope, -1 if yama isn't enabled
+	PtraceScope int
+	//The tools skavo can use that are on the PATH
+	Tools []string
+	//The checksum of the agent binary, to verify the copy
+	AgentSha256 string
+}
+
+type Process struct {
+	Pid  int
+	PPid int
+	Uid  int
+	//The user name from /etc/passwd, empty if the uid isn't listed
+	User      string
+	StartTime time.Time
+	//Resident memory in bytes
+	Rss   int64
+	State string
+	//The path of the executable, empty if it can't be read
+	Exe     string
+	Command []string
+}
+
+//Install a go toolchain and build delve with it, the install log is sent as Log responses as it runs
+type InstallParams struct {
+	DelveVersion string
+	GoVersion    string
+	GoArch       string
+	//The checksum of the go archive, the agent uses the checksum next to the archive if it's empty
+	GoSha256 string
+}
+
+type InstallResult struct {
+	DlvPath string
+}
+
+//Start delve in the background and keep track of it
+type LaunchParams struct {
+	//The dlv binary to run
+	Dlv string
+	//The port delve listens on, on the loopback interface
+	Port string
+	//Extra flags for dlv
+	Flags []string
+	//Attach to Pid, or if Restart is set, kill Pid and run Command under delve
+	Pid     int
+	Restart bool
+	Command []string
+}
+
+type Status struct {
+	//Delve is running under the agent's supervisor
+	Running bool
+	//The supervisor's pid
+	Pid    int
+	DlvPid int
+	Launch LaunchParams
+	//When delve was started
+	StartTime time.Time
+	ExitCode  int
+	//Why delve isn't running
+	Error string `json:",omitempty"`
+	//The end of delve's output
+	Log string
+}
+
+//Run a command in the target
+type ExecParams struct {
+	Command []string
+	Stdin   []byte `json:",omitempty"`
+}
+
+type ExecResult struct {
+	Stdout   []byte
+	Stderr   []byte
+	ExitCode int
+}
+
+//Read part of a file, reads to the end of the file if it's shorter
+type ReadParams struct {
+	Path   string
+	Offset int64
+	Length int
+}
+
+//Write a file, creating the directories it's in
+type WriteParams struct {
+	Path string
+	Data []byte
+	Mode uint32
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/ncsnw/skavo/pkg/agent/protocol"
+)
+
+//bit of CAP_SYS_PTRACE in the capability sets in /proc/self/status
+const capSysPtrace = 19
+
+var tools = []string{"sh", "tar", "nc", "socat", "apk"}
+
+func capabilities() (*protocol.Capabilities, error) {
+	sum, err := selfSha256()
+	if err != nil {
+		return nil, err
+	}
+	caps := &protocol.Capabilities{
+		Arch:        runtime.GOARCH,
+		Uid:         os.Getuid(),
+		SysPtrace:   hasCapability(capSysPtrace),
+		PtraceScope: -1,
+		AgentSha256: sum,
+	}
+	if scope, err := ioutil.ReadFile("/proc/sys/kernel/yama/ptrace_scope"); err == nil {
+		caps.PtraceScope, _ = strconv.Atoi(strings.TrimSpace(string(scope)))
+	}
+	for _, tool := range tools {
+		if _, err := exec.LookPath(tool); err == nil {
+			caps.Tools = append(caps.Tools, tool)
+		}
+	}
+	return caps, nil
+}
+
+func hasCapability(bit uint) bool {
+	status, err := ioutil.ReadFile("/proc/self/status")
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(status), "\n") {
+		if strings.HasPrefix(line, "CapEff:") {
+			caps, err := strconv.ParseUint(strings.TrimSpace(strings.TrimPrefix(line, "CapEff:")), 16, 64)
+			return err == nil && caps&(1<<bit) != 0
+		}
+	}
+	return false
+}
+
+func selfSha256() (string, error) {
+	f, err := os.Open("/proc/self/exe")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
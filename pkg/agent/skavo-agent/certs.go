@@ -0,0 +1,43 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	_ "embed"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/ncsnw/skavo/pkg/agent/protocol"
+)
+
+//Mozilla's CA certificates, as packaged by ca-certificates, for images that don't have any
+//
+//go:embed ca-certificates.pem
+var caCertificates []byte
+
+//Where the bundled certificates are written for the go toolchain
+const caFile = protocol.Dir + "/ca-certificates.pem"
+
+//Trust the container's CA certificates and the bundled ones for downloads. Returns the env the go toolchain needs to
+//trust the bundled ones, which is only set when the container has none of its own, so an image's private CAs still
+//work for the module proxy.
+func trustCertificates() ([]string, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	//the container's certificates are only missing if the pool is empty before adding the bundle
+	missing := len(pool.Subjects()) == 0
+	pool.AppendCertsFromPEM(caCertificates)
+	httpClient.Transport = &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: &tls.Config{RootCAs: pool},
+	}
+	if !missing {
+		return nil, nil
+	}
+	if err := ioutil.WriteFile(caFile, caCertificates, 0644); err != nil {
+		return nil, err
+	}
+	return []string{"SSL_CERT_FILE=" + caFile}, nil
+}
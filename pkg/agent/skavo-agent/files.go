@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/ncsnw/skavo/pkg/agent/protocol"
+)
+
+func execCommand(p protocol.ExecParams) (*protocol.ExecResult, error) {
+	stdout := new(bytes.Buffer)
+	stderr := new(bytes.Buffer)
+	cmd := exec.Command(p.Command[0], p.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(p.Stdin)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	err := cmd.Run()
+	if _, ok := err.(*exec.ExitError); err != nil && !ok {
+		return nil, err
+	}
+	return &protocol.ExecResult{
+		Stdout:   stdout.Bytes(),
+		Stderr:   stderr.Bytes(),
+		ExitCode: cmd.ProcessState.ExitCode(),
+	}, nil
+}
+
+func readFile(p protocol.ReadParams) ([]byte, error) {
+	f, err := os.Open(p.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data := make([]byte, p.Length)
+	n, err := f.ReadAt(data, p.Offset)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return data[:n], nil
+}
+
+func writeFile(p protocol.WriteParams) error {
+	if err := os.MkdirAll(filepath.Dir(p.Path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(p.Path, p.Data, os.FileMode(p.Mode))
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ncsnw/skavo/pkg/agent/protocol"
+)
+
+const goDownloads = "https://dl.google.com/go/"
+
+var httpClient = &http.Client{Timeout: 10 * time.Minute}
+
+//Install the go toolchain the process was built with and build delve with it. The log goes to out and the install log.
+func install(p protocol.InstallParams, out io.Writer) (*protocol.InstallResult, error) {
+	logFile, err := os.Create(protocol.InstallLog)
+	if err != nil {
+		return nil, err
+	}
+	defer logFile.Close()
+	log := io.MultiWriter(logFile, out)
+	dlv := protocol.Dir + "/dlv-" + p.DelveVersion
+	if err := installDelve(p, dlv, log); err != nil {
+		fmt.Fprintln(log, err)
+		return nil, err
+	}
+	return &protocol.InstallResult{DlvPath: dlv}, nil
+}
+
+func installDelve(p protocol.InstallParams, dlv string, log io.Writer) error {
+	if _, err := os.Stat(dlv); err == nil {
+		fmt.Fprintln(log, "Delve Already Installed")
+		return nil
+	}
+	fmt.Fprintln(log, protocol.StepMarker+"packages")
+	if _, err := exec.LookPath("apk"); err == nil {
+		//alpine is special and the regular linux binaries need glibc
+		if err := run(log, nil, "apk", "add", "--no-cache", "-q", "libc6-compat"); err != nil {
+			fmt.Fprintf(log, "Couldn't install libc6-compat, the go toolchain may not run: %+v\n", err)
+		}
+	}
+	certEnv, err := trustCertificates()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(log, protocol.StepMarker+"toolchain")
+	goroot := filepath.Join(protocol.Dir, p.GoVersion, "go")
+	gobin := filepath.Join(goroot, "bin", "go")
+	if _, err := os.Stat(gobin); err != nil {
+		if err := installGo(p, log); err != nil {
+			return err
+		}
+	}
+	if err := run(log, nil, gobin, "version"); err != nil {
+		return err
+	}
+	env := append(os.Environ(),
+		"GOROOT="+goroot,
+		"GOPATH="+protocol.Dir+"/gopath",
+		"GOCACHE="+protocol.Dir+"/gocache",
+		"GO111MODULE=on",
+		"GOFLAGS=-mod=mod",
+		"GOTOOLCHAIN=local",
+		"CGO_ENABLED=0",
+	)
+	env = append(env, certEnv...)
+	build := protocol.Dir + "/build-" + p.DelveVersion
+	_ = os.RemoveAll(build)
+	if err := os.MkdirAll(build, 0755); err != nil {
+		return err
+	}
+	defer os.RemoveAll(build)
+	if err := runIn(build, log, env, gobin, "mod", "init", "skavo-dlv"); err != nil {
+		return err
+	}
+	fmt.Fprintln(log, protocol.StepMarker+"download")
+	//module downloads are verified against the go checksum database
+	if err := runIn(build, log, env, gobin, "get", "github.com/go-delve/delve@"+p.DelveVersion); err != nil {
+		return err
+	}
+	fmt.Fprintln(log, protocol.StepMarker+"build")
+	return runIn(build, log, env, gobin, "build", "-o", dlv, "github.com/go-delve/delve/cmd/dlv")
+}
+
+//Download the go archive, verify it against the checksum from go.dev and extract it. The toolchain is extracted next to
+//where it goes and moved there once it's complete, so an interrupted install is started over.
+func installGo(p protocol.InstallParams, log io.Writer) error {
+	archive := fmt.Sprintf("%s.linux-%s.tar.gz", p.GoVersion, p.GoArch)
+	//the checksum next to the archive comes from the same host, so it wouldn't verify anything
+	expected := p.GoSha256
+	if expected == "" {
+		return fmt.Errorf("no checksum from go.dev to verify %s with", archive)
+	}
+	fmt.Fprintf(log, "Downloading %s\n", goDownloads+archive)
+	path := filepath.Join(protocol.Dir, archive)
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(path)
+	hash := sha256.New()
+	err = download(goDownloads+archive, io.MultiWriter(f, hash))
+	f.Close()
+	if err != nil {
+		return err
+	}
+	if sum := hex.EncodeToString(hash.Sum(nil)); sum != expected {
+		return fmt.Errorf("checksum of %s is %s, expected %s", archive, sum, expected)
+	}
+	fmt.Fprintf(log, "%s: OK\n", archive)
+	dest := filepath.Join(protocol.Dir, p.GoVersion)
+	partial := dest + ".partial"
+	if err := os.RemoveAll(partial); err != nil {
+		return err
+	}
+	defer os.RemoveAll(partial)
+	if err := extract(path, partial); err != nil {
+		return err
+	}
+	//a toolchain left half extracted by an older agent
+	if err := os.RemoveAll(dest); err != nil {
+		return err
+	}
+	return os.Rename(partial, dest)
+}
+
+//Download url to out
+func download(url string, out io.Writer) error {
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %+v", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download %s: %s", url, resp.Status)
+	}
+	_, err = io.Copy(out, resp.Body)
+	return err
+}
+
+func extract(archive string, dest string) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(dest, hdr.Name)
+		if !strings.HasPrefix(path, filepath.Clean(dest)+string(filepath.Separator)) {
+			return fmt.Errorf("%s in %s is outside of %s", hdr.Name, archive, dest)
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(path, 0755)
+		case tar.TypeReg:
+			err = extractFile(path, tr, os.FileMode(hdr.Mode))
+		case tar.TypeSymlink:
+			err = os.Symlink(hdr.Linkname, path)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func extractFile(path string, r io.Reader, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(f, r)
+	return err
+}
+
+func run(log io.Writer, env []string, name string, args ...string) error {
+	return runIn("", log, env, name, args...)
+}
+
+func runIn(dir string, log io.Writer, env []string, name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	cmd.Dir = dir
+	cmd.Env = env
+	cmd.Stdout = log
+	cmd.Stderr = log
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s %s failed: %+v", filepath.Base(name), strings.Join(args, " "), err)
+	}
+	return nil
+}
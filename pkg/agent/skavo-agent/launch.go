@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ncsnw/skavo/pkg/agent/protocol"
+)
+
+//The agent runs itself with this argument to supervise delve after the exec that launched it ends
+const superviseCommand = "supervise"
+
+const (
+	statusFile = protocol.Dir + "/dlv.json"
+	dlvLog     = protocol.Dir + "/dlv.log"
+	//How many lines of delve's output status reports
+	logLines = 20
+	//How long launch waits for delve to listen
+	launchTimeout = 30 * time.Second
+)
+
+//Start the supervisor in its own session, so it outlives the exec, and wait for delve to listen or exit
+func launch(p protocol.LaunchParams) (*protocol.Status, error) {
+	if s, err := status(); err == nil && s.Running {
+		return nil, fmt.Errorf("delve is already running (pid %d) on port %s", s.DlvPid, s.Launch.Port)
+	}
+	_ = os.Remove(statusFile)
+	params, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	log, err := os.Create(dlvLog)
+	if err != nil {
+		return nil, err
+	}
+	defer log.Close()
+	self, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(self, superviseCommand, string(params))
+	cmd.Stdout = log
+	cmd.Stderr = log
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start the supervisor: %+v", err)
+	}
+	_ = cmd.Process.Release()
+	port, _ := strconv.Atoi(p.Port)
+	deadline := time.Now().Add(launchTimeout)
+	for {
+		s, err := status()
+		if err == nil && (!s.Running || isListening(port)) {
+			return s, nil
+		}
+		if time.Now().After(deadline) {
+			if err != nil {
+				return nil, fmt.Errorf("delve didn't start in %s: %+v", launchTimeout, err)
+			}
+			return s, nil
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
+//Runs delve and records its status until it exits
+func supervise() {
+	var p protocol.LaunchParams
+	if err := json.Unmarshal([]byte(os.Args[2]), &p); err != nil {
+		fmt.Printf("invalid launch params: %+v\n", err)
+		os.Exit(1)
+	}
+	s := &protocol.Status{Pid: os.Getpid(), Launch: p}
+	//dlv only parses its flags after the subcommand
+	flags := append([]string{"--headless", "--listen=127.0.0.1:" + p.Port, "--api-version=2", "--accept-multiclient"}, p.Flags...)
+	var args []string
+	if p.Restart {
+		fmt.Printf("Restarting: %d, %s\n", p.Pid, strings.Join(p.Command, " "))
+		stopProcess(p.Pid)
+		args = append(append(append([]string{"exec"}, flags...), p.Command[0], "--"), p.Command[1:]...)
+	} else {
+		args = append(append([]string{"attach"}, flags...), strconv.Itoa(p.Pid))
+	}
+	cmd := exec.Command(p.Dlv, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		s.Error = fmt.Sprintf("failed to start delve: %+v", err)
+		writeStatus(s)
+		os.Exit(1)
+	}
+	s.Running = true
+	s.DlvPid = cmd.Process.Pid
+	s.StartTime = time.Now()
+	writeStatus(s)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		_ = cmd.Process.Signal(sig)
+	}()
+	err := cmd.Wait()
+	s.Running = false
+	s.ExitCode = cmd.ProcessState.ExitCode()
+	if err != nil {
+		s.Error = fmt.Sprintf("delve exited: %+v", err)
+	}
+	writeStatus(s)
+}
+
+//Interrupt delve through its supervisor, which detaches delve from a process it attached to, and wait for it to exit
+func stop() (*protocol.Status, error) {
+	s, err := status()
+	if err != nil || !s.Running {
+		return s, err
+	}
+	if err := syscall.Kill(s.Pid, syscall.SIGINT); err != nil {
+		return nil, err
+	}
+	for i := 0; i < 50 && isAlive(s.Pid); i++ {
+		time.Sleep(200 * time.Millisecond)
+	}
+	return status()
+}
+
+//Terminate the process and give it a few seconds to exit before killing it
+func stopProcess(pid int) {
+	_ = syscall.Kill(pid, syscall.SIGTERM)
+	for i := 0; i < 50; i++ {
+		if !isAlive(pid) {
+			return
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+	_ = syscall.Kill(pid, syscall.SIGKILL)
+}
+
+func isAlive(pid int) bool {
+	stat, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return false
+	}
+	//zombies have exited
+	fields := strings.Fields(string(stat[strings.LastIndex(string(stat), ")")+1:]))
+	return len(fields) > 0 && fields[0] != "Z"
+}
+
+func writeStatus(s *protocol.Status) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		fmt.Printf("failed to write status: %+v\n", err)
+		return
+	}
+	if err := ioutil.WriteFile(statusFile+".tmp", data, 0644); err != nil {
+		fmt.Printf("failed to write status: %+v\n", err)
+		return
+	}
+	_ = os.Rename(statusFile+".tmp", statusFile)
+}
+
+func status() (*protocol.Status, error) {
+	data, err := ioutil.ReadFile(statusFile)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("delve hasn't been launched")
+	}
+	if err != nil {
+		return nil, err
+	}
+	s := &protocol.Status{}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	if s.Running && !isAlive(s.Pid) {
+		s.Running = false
+		s.Error = "the supervisor exited without recording delve's exit"
+	}
+	if out, err := ioutil.ReadFile(dlvLog); err == nil {
+		lines := strings.Split(strings.TrimRight(string(out), "\n"), "\n")
+		if len(lines) > logLines {
+			lines = lines[len(lines)-logLines:]
+		}
+		s.Log = strings.Join(lines, "\n")
+	}
+	return s, nil
+}
+
+//Check /proc/net/tcp for a listening socket on the port
+func isListening(port int) bool {
+	for _, file := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			//sl local_address rem_address st ...
+			fields := strings.Fields(line)
+			if len(fields) > 3 && fields[3] == "0A" && strings.HasSuffix(fields[1], fmt.Sprintf(":%04X", port)) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+//The skavo agent runs in the target container. Skavo copies it in and talks to it over exec with json on stdin and
+//stdout, so it works on images without a usable userland. It only uses the standard library so it builds static.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/ncsnw/skavo/pkg/agent/protocol"
+)
+
+//log receives output of the request as it runs
+type handler func(params json.RawMessage, log io.Writer) (interface{}, error)
+
+var handlers = map[string]handler{
+	protocol.MethodCapabilities: func(json.RawMessage, io.Writer) (interface{}, error) { return capabilities() },
+	protocol.MethodProcesses:    func(json.RawMessage, io.Writer) (interface{}, error) { return listProcesses() },
+	protocol.MethodInstall: func(params json.RawMessage, log io.Writer) (interface{}, error) {
+		var p protocol.InstallParams
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+		return install(p, log)
+	},
+	protocol.MethodLaunch: func(params json.RawMessage, _ io.Writer) (interface{}, error) {
+		var p protocol.LaunchParams
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+		return launch(p)
+	},
+	protocol.MethodStatus: func(json.RawMessage, io.Writer) (interface{}, error) { return status() },
+	protocol.MethodStop:   func(json.RawMessage, io.Writer) (interface{}, error) { return stop() },
+	protocol.MethodExec: func(params json.RawMessage, _ io.Writer) (interface{}, error) {
+		var p protocol.ExecParams
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+		return execCommand(p)
+	},
+	protocol.MethodRead: func(params json.RawMessage, _ io.Writer) (interface{}, error) {
+		var p protocol.ReadParams
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+		return readFile(p)
+	},
+	protocol.MethodWrite: func(params json.RawMessage, _ io.Writer) (interface{}, error) {
+		var p protocol.WriteParams
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+		return nil, writeFile(p)
+	},
+}
+
+//Writes responses one at a time, requests are handled concurrently
+type responder struct {
+	mu  sync.Mutex
+	out *json.Encoder
+}
+
+func (r *responder) send(res protocol.Response) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.out.Encode(res); err != nil {
+		os.Exit(1)
+	}
+}
+
+//Sends what's written as Log responses for a request
+type logWriter struct {
+	id  int
+	res *responder
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.res.send(protocol.Response{ID: w.id, Log: string(p)})
+	return len(p), nil
+}
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == superviseCommand {
+		supervise()
+		return
+	}
+	if err := os.MkdirAll(protocol.Dir, 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create %s: %+v\n", protocol.Dir, err)
+		os.Exit(1)
+	}
+	in := bufio.NewScanner(os.Stdin)
+	in.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	res := &responder{out: json.NewEncoder(os.Stdout)}
+	var wg sync.WaitGroup
+	for in.Scan() {
+		if len(in.Bytes()) == 0 {
+			continue
+		}
+		var req protocol.Request
+		if err := json.Unmarshal(in.Bytes(), &req); err != nil {
+			res.send(protocol.Response{Error: fmt.Sprintf("invalid request: %+v", err)})
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res.send(handle(req, &logWriter{id: req.ID, res: res}))
+		}()
+	}
+	//finish what was asked before stdin closed
+	wg.Wait()
+}
+
+func handle(req protocol.Request, log io.Writer) protocol.Response {
+	res := protocol.Response{ID: req.ID}
+	h, ok := handlers[req.Method]
+	if !ok {
+		res.Error = "unknown method " + req.Method
+		return res
+	}
+	result, err := h(req.Params, log)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	res.Result, err = json.Marshal(result)
+	if err != nil {
+		res.Error = err.Error()
+	}
+	return res
+}
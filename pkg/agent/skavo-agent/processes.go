@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ncsnw/skavo/pkg/agent/protocol"
+)
+
+//the kernel reports process start times in USER_HZ, which is 100 on every linux platform go supports
+const clockTicksPerSecond = 100
+
+func listProcesses() ([]protocol.Process, error) {
+	bootTime, err := readBootTime()
+	if err != nil {
+		return nil, err
+	}
+	users := readUsers()
+	dirs, err := filepath.Glob("/proc/[0-9]*")
+	if err != nil {
+		return nil, err
+	}
+	processes := make([]protocol.Process, 0, len(dirs))
+	for _, dir := range dirs {
+		pid, err := strconv.Atoi(filepath.Base(dir))
+		if err != nil || pid == os.Getpid() {
+			continue
+		}
+		//processes can exit while they are read, skip them
+		p, ok := readProcess(dir, pid, bootTime)
+		//skip kernel threads, they have no command line
+		if !ok || len(p.Command) == 0 {
+			continue
+		}
+		p.User = users[p.Uid]
+		processes = append(processes, p)
+	}
+	return processes, nil
+}
+
+func readProcess(dir string, pid int, bootTime int64) (protocol.Process, bool) {
+	p := protocol.Process{Pid: pid, Uid: -1}
+	cmdline, err := ioutil.ReadFile(dir + "/cmdline")
+	if err != nil {
+		return p, false
+	}
+	if len(cmdline) > 0 {
+		for _, arg := range bytes.Split(bytes.TrimSuffix(cmdline, []byte{0}), []byte{0}) {
+			p.Command = append(p.Command, string(arg))
+		}
+	}
+	p.Exe, _ = os.Readlink(dir + "/exe")
+	stat, err := ioutil.ReadFile(dir + "/stat")
+	if err != nil {
+		return p, false
+	}
+	//the command name in parens can contain spaces, so the fields start after the last paren
+	fields := strings.Fields(string(stat[bytes.LastIndexByte(stat, ')')+1:]))
+	if len(fields) > 19 {
+		startTicks, _ := strconv.ParseInt(fields[19], 10, 64)
+		p.StartTime = time.Unix(bootTime+startTicks/clockTicksPerSecond, 0)
+	}
+	status, err := ioutil.ReadFile(dir + "/status")
+	if err != nil {
+		return p, false
+	}
+	for _, line := range strings.Split(string(status), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "State:":
+			p.State = strings.Join(fields[1:], " ")
+		case "PPid:":
+			p.PPid, _ = strconv.Atoi(fields[1])
+		case "Uid:":
+			p.Uid, _ = strconv.Atoi(fields[1])
+		case "VmRSS:":
+			kb, _ := strconv.ParseInt(fields[1], 10, 64)
+			p.Rss = kb * 1024
+		}
+	}
+	return p, true
+}
+
+func readBootTime() (int64, error) {
+	stat, err := ioutil.ReadFile("/proc/stat")
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range strings.Split(string(stat), "\n") {
+		if strings.HasPrefix(line, "btime ") {
+			return strconv.ParseInt(strings.TrimSpace(strings.TrimPrefix(line, "btime ")), 10, 64)
+		}
+	}
+	return 0, fmt.Errorf("no btime in /proc/stat")
+}
+
+//Read the user names from /etc/passwd, images without one just don't get names
+func readUsers() map[int]string {
+	users := make(map[int]string)
+	passwd, err := ioutil.ReadFile("/etc/passwd")
+	if err != nil {
+		return users
+	}
+	for _, line := range strings.Split(string(passwd), "\n") {
+		//name:password:uid:...
+		fields := strings.Split(line, ":")
+		if len(fields) > 2 {
+			if uid, err := strconv.Atoi(fields[2]); err == nil {
+				users[uid] = fields[0]
+			}
+		}
+	}
+	return users
+}
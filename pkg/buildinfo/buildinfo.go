@@ -0,0 +1,101 @@
+package buildinfo
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var elfArches = map[elf.Machine]string{
+	elf.EM_X86_64:  "amd64",
+	elf.EM_386:     "386",
+	elf.EM_AARCH64: "arm64",
+	elf.EM_ARM:     "armv6l",
+	elf.EM_PPC64:   "ppc64le",
+	elf.EM_S390:    "s390x",
+}
+
+const buildInfoMagic = "\xff Go buildinf:"
+
+//Read runtime.buildVersion from the .go.buildinfo section the same way `go version` does, returns the go version and
+//architecture
+func ReadGoVersion(r io.ReaderAt) (string, string, error) {
+	f, err := elf.NewFile(r)
+	if err != nil {
+		return "", "", err
+	}
+	arch, ok := elfArches[f.Machine]
+	if !ok {
+		return "", "", fmt.Errorf("unsupported architecture %s", f.Machine)
+	}
+	section := f.Section(".go.buildinfo")
+	if section == nil {
+		return "", "", fmt.Errorf("not a go binary, or built with a go version older than 1.13")
+	}
+	data, err := section.Data()
+	if err != nil {
+		return "", "", err
+	}
+	if len(data) < 32 || !bytes.HasPrefix(data, []byte(buildInfoMagic)) {
+		return "", "", fmt.Errorf("unrecognized build info")
+	}
+	ptrSize := int(data[14])
+	flags := data[15]
+	var version string
+	if flags&2 != 0 {
+		//since go 1.18 the version is inlined after the header
+		length, n := binary.Uvarint(data[32:])
+		if n <= 0 || 32+n+int(length) > len(data) {
+			return "", "", fmt.Errorf("unrecognized build info")
+		}
+		version = string(data[32+n : 32+n+int(length)])
+	} else {
+		var order binary.ByteOrder = binary.LittleEndian
+		if flags&1 != 0 {
+			order = binary.BigEndian
+		}
+		readPtr := func(b []byte) uint64 {
+			if ptrSize == 4 {
+				return uint64(order.Uint32(b))
+			}
+			return order.Uint64(b)
+		}
+		//the header points to the string header of runtime.buildVersion
+		header, err := readVirtual(f, readPtr(data[16:]), 2*ptrSize)
+		if err != nil {
+			return "", "", err
+		}
+		value, err := readVirtual(f, readPtr(header), int(readPtr(header[ptrSize:])))
+		if err != nil {
+			return "", "", err
+		}
+		version = string(value)
+	}
+	//drop experiments, like "go1.21.0 X:boringcrypto"
+	return strings.Fields(version + " ")[0], arch, nil
+}
+
+func readVirtual(f *elf.File, addr uint64, size int) ([]byte, error) {
+	for _, prog := range f.Progs {
+		if prog.Type == elf.PT_LOAD && prog.Vaddr <= addr && addr+uint64(size) <= prog.Vaddr+prog.Filesz {
+			data := make([]byte, size)
+			_, err := prog.ReadAt(data, int64(addr-prog.Vaddr))
+			return data, err
+		}
+	}
+	return nil, fmt.Errorf("address %x is not in the binary", addr)
+}
+
+//The main module's path from the module info go embeds as runtime.modinfo, empty if it has none
+func MainModule(modinfo string) string {
+	for _, line := range strings.Split(modinfo, "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) > 1 && fields[0] == "mod" {
+			return fields[1]
+		}
+	}
+	return ""
+}
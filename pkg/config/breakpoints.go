@@ -0,0 +1,78 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/ncsnw/skavo/pkg/util"
+)
+
+//Where the breakpoints are kept, relative to the project
+const breakpointsFile = ".skavo/breakpoints.yaml"
+
+type Breakpoint struct {
+	//Where to break, like file.go:123 or a function, as with dlv's break command
+	Location string `yaml:"location"`
+	//Only stop when this expression is true
+	Condition string `yaml:"condition,omitempty"`
+	//Only stop when the hit count matches this, like > 10 or % 2
+	HitCount string `yaml:"hitCount,omitempty"`
+	//Print the expressions and continue instead of stopping
+	Tracepoint  bool     `yaml:"tracepoint,omitempty"`
+	Expressions []string `yaml:"expressions,omitempty"`
+}
+
+type Breakpoints struct {
+	Breakpoints []Breakpoint `yaml:"breakpoints"`
+}
+
+//Find .skavo/breakpoints.yaml in the working directory or the closest parent. If there isn't one, it goes next to
+//.skavo.yaml, or in the working directory if there isn't one either.
+func BreakpointsPath() string {
+	dir, err := os.Getwd()
+	util.MaybePanic(err)
+	for d := dir; ; {
+		file := filepath.Join(d, breakpointsFile)
+		if _, err := os.Stat(file); err == nil {
+			return file
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			break
+		}
+		d = parent
+	}
+	if project := projectConfigPath(); project != "" {
+		return filepath.Join(filepath.Dir(project), breakpointsFile)
+	}
+	return filepath.Join(dir, breakpointsFile)
+}
+
+//Read the breakpoints from file, none if it doesn't exist
+func LoadBreakpoints(file string) []Breakpoint {
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	util.MaybePanic(err)
+	bps := &Breakpoints{}
+	if err := yaml.UnmarshalStrict(data, bps); err != nil {
+		panic(fmt.Errorf("failed to parse %s: %+v", file, err))
+	}
+	return bps.Breakpoints
+}
+
+func SaveBreakpoints(file string, breakpoints []Breakpoint) error {
+	data, err := yaml.Marshal(&Breakpoints{breakpoints})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, data, 0644)
+}
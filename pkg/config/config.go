@@ -0,0 +1,173 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+	"k8s.io/client-go/util/homedir"
+
+	"github.com/ncsnw/skavo/pkg/util"
+)
+
+const projectConfigName = ".skavo.yaml"
+
+type SourceMapping struct {
+	//The source path on this machine
+	From string `yaml:"from"`
+	//The source path the binary was built from
+	To string `yaml:"to"`
+}
+
+//A Profile holds the values for skavo's flags, flags given on the command line win
+type Profile struct {
+	Kubeconfig     string          `yaml:"kubeconfig"`
+	Context        string          `yaml:"context"`
+	Namespace      string          `yaml:"namespace"`
+	Backend        string          `yaml:"backend"`
+	Pod            string          `yaml:"pod"`
+	Target         string          `yaml:"target"`
+	Selector       string          `yaml:"selector"`
+	FieldSelector  string          `yaml:"fieldSelector"`
+	Node           string          `yaml:"node"`
+	Container      string          `yaml:"container"`
+	Process        string          `yaml:"process"`
+	Address        string          `yaml:"address"`
+	LocalPort      string          `yaml:"localPort"`
+	PodPort        string          `yaml:"podPort"`
+	Mode           string          `yaml:"mode"`
+	DlvFlags       []string        `yaml:"dlvFlags"`
+	DelveVersion   string          `yaml:"delveVersion"`
+	OnExit         string          `yaml:"onExit"`
+	Cleanup        bool            `yaml:"cleanup"`
+	SourceMappings []SourceMapping `yaml:"sourceMappings"`
+}
+
+type Config struct {
+	Profiles map[string]*Profile `yaml:"profiles"`
+}
+
+//Load the user config, then the project config. Project profiles replace user profiles with the same name.
+func Load() *Config {
+	cfg := &Config{Profiles: make(map[string]*Profile)}
+	for _, file := range []string{userConfigPath(), projectConfigPath()} {
+		if file == "" {
+			continue
+		}
+		for name, profile := range readConfig(file).Profiles {
+			cfg.Profiles[name] = profile
+		}
+	}
+	return cfg
+}
+
+func (c *Config) Profile(name string) *Profile {
+	profile, ok := c.Profiles[name]
+	if !ok {
+		panic(fmt.Errorf("profile %s not found in %s or %s", name, projectConfigName, userConfigPath()))
+	}
+	return profile
+}
+
+func readConfig(file string) *Config {
+	cfg := &Config{}
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return cfg
+	}
+	util.MaybePanic(err)
+	if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+		panic(fmt.Errorf("failed to parse %s: %+v", file, err))
+	}
+	return cfg
+}
+
+func userConfigPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		dir = filepath.Join(homedir.HomeDir(), ".config")
+	}
+	return filepath.Join(dir, "skavo", "config.yaml")
+}
+
+//find .skavo.yaml in the working directory or the closest parent
+func projectConfigPath() string {
+	dir, err := os.Getwd()
+	util.MaybePanic(err)
+	for {
+		file := filepath.Join(dir, projectConfigName)
+		if _, err := os.Stat(file); err == nil {
+			return file
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+//Set the flags that weren't given on the command line to the profile's values
+func (p *Profile) Apply(flags *flag.FlagSet) {
+	given := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+	values := map[string]string{
+		"kubeconfig":     p.Kubeconfig,
+		"context":        p.Context,
+		"namespace":      p.Namespace,
+		"backend":        p.Backend,
+		"pod":            p.Pod,
+		"target":         p.Target,
+		"selector":       p.Selector,
+		"field-selector": p.FieldSelector,
+		"node":           p.Node,
+		"container":      p.Container,
+		"process":        p.Process,
+		"address":        p.Address,
+		"localport":      p.LocalPort,
+		"podport":        p.PodPort,
+		"delve-version":  p.DelveVersion,
+		"on-exit":        p.OnExit,
+	}
+	if p.Cleanup {
+		values["cleanup"] = "true"
+	}
+	switch p.Mode {
+	case "", "attach":
+	case "restart", "relaunch":
+		values[p.Mode] = "true"
+	default:
+		panic(fmt.Errorf("unknown mode %s, expected attach, restart or relaunch", p.Mode))
+	}
+	for name, value := range values {
+		if value != "" && !given[name] && flags.Lookup(name) != nil {
+			util.MaybePanic(flags.Set(name, value))
+		}
+	}
+	//each dlv flag is its own value, so values with spaces stay whole
+	if !given["dlvflags"] && flags.Lookup("dlvflags") != nil {
+		for _, dlvFlag := range p.DlvFlags {
+			util.MaybePanic(flags.Set("dlvflags", dlvFlag))
+		}
+	}
+}
+
+//Print the source mappings in the formats debugger clients expect
+func (p *Profile) PrintSourceMappings() {
+	if len(p.SourceMappings) == 0 {
+		return
+	}
+	fmt.Println("Source mappings for your debugger client:")
+	vscode := make([]string, len(p.SourceMappings))
+	for i, m := range p.SourceMappings {
+		vscode[i] = fmt.Sprintf("{\"from\": %q, \"to\": %q}", m.From, m.To)
+		fmt.Printf("  dlv connect: config substitute-path %s %s\n", m.From, m.To)
+	}
+	fmt.Printf("  VS Code launch.json: \"substitutePath\": [%s]\n", strings.Join(vscode, ", "))
+}
@@ -0,0 +1,174 @@
+package delve
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"time"
+
+	"github.com/ncsnw/skavo/pkg/config"
+	"github.com/ncsnw/skavo/pkg/dlv"
+)
+
+//Set the breakpoints from the breakpoints file, stopping the process only while they're set. Breakpoints that can't
+//be set, like ones on lines that changed, are skipped and saved back unchanged.
+func (pd *PodDelve) applyBreakpoints() {
+	if pd.BreakpointsFile == "" {
+		return
+	}
+	breakpoints := config.LoadBreakpoints(pd.BreakpointsFile)
+	pd.savedBreakpoints = breakpoints
+	if len(breakpoints) == 0 {
+		return
+	}
+	client, err := dlv.Connect(pd.localAddr())
+	if err != nil {
+		panic(err)
+	}
+	state, err := client.State()
+	if err == nil && state.Running {
+		_, err = client.Halt()
+	}
+	if err != nil {
+		client.Close()
+		panic(fmt.Errorf("failed to stop the process to set breakpoints: %+v", err))
+	}
+	pd.breakpointLocations = make(map[int]string)
+	pd.unappliedBreakpoints = nil
+	set := 0
+	for _, bp := range breakpoints {
+		if err := pd.createBreakpoint(client, bp); err != nil {
+			fmt.Fprintf(os.Stderr, "Skipping the breakpoint at %s: %+v\n", bp.Location, err)
+			pd.unappliedBreakpoints = append(pd.unappliedBreakpoints, bp)
+			continue
+		}
+		set++
+	}
+	fmt.Printf("Set %d of %d breakpoints from %s\n", set, len(breakpoints), pd.BreakpointsFile)
+	if state.Running {
+		err = client.ContinueAndClose()
+	} else {
+		err = client.Close()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to resume the process after setting breakpoints: %+v\n", err)
+	}
+}
+
+//Set a breakpoint at each place the location resolves to, or none if one of them can't be set
+func (pd *PodDelve) createBreakpoint(client *dlv.Client, bp config.Breakpoint) error {
+	locations, err := client.FindLocation(bp.Location)
+	if err != nil {
+		return err
+	}
+	created := make(map[int]bool)
+	for _, l := range locations {
+		set, err := client.CreateBreakpoint(&dlv.Breakpoint{
+			Addr:       l.PC,
+			Addrs:      l.PCs,
+			Cond:       bp.Condition,
+			HitCond:    bp.HitCount,
+			Tracepoint: bp.Tracepoint,
+			Variables:  bp.Expressions,
+		})
+		if err != nil {
+			clearBreakpoints(client, created)
+			return err
+		}
+		created[set.ID] = true
+	}
+	for id := range created {
+		pd.breakpointLocations[id] = bp.Location
+	}
+	return nil
+}
+
+//How long the breakpoints saver waits between two looks at the breakpoints
+const breakpointSaveInterval = 2 * time.Second
+
+//Save the breakpoints whenever they change during the session, a crash-restart takes delve down with the process and
+//releaseProcess can't reach it anymore. Delve only lists the breakpoints once the process is stopped, which is when
+//they can be changed.
+func (pd *PodDelve) keepSavingBreakpoints() {
+	if pd.BreakpointsFile == "" {
+		return
+	}
+	client, err := dlv.Connect(pd.localAddr())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to connect to delve, the breakpoints are only saved when skavo exits: %+v\n", err)
+		return
+	}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	pd.stopSavingBreakpoints = func() {
+		close(stop)
+		//closing the connection ends a list that waits for the process to stop
+		client.Close()
+		<-done
+	}
+	go func() {
+		defer close(done)
+		for {
+			if err := pd.saveBreakpoints(client); err != nil {
+				select {
+				case <-stop:
+				default:
+					fmt.Fprintf(os.Stderr, "Stopped saving the breakpoints to %s: %+v\n", pd.BreakpointsFile, err)
+				}
+				return
+			}
+			select {
+			case <-stop:
+				return
+			case <-time.After(breakpointSaveInterval):
+			}
+		}
+	}()
+}
+
+//Write the breakpoints set now back to the breakpoints file if they changed since the last save, keeping the locations
+//as they were written in it, along with the ones from the file that couldn't be set
+func (pd *PodDelve) saveBreakpoints(client *dlv.Client) error {
+	if pd.BreakpointsFile == "" {
+		return nil
+	}
+	current, err := client.ListBreakpoints()
+	if err != nil {
+		return err
+	}
+	saved := make([]config.Breakpoint, 0, len(current))
+	seen := make(map[string]bool)
+	for _, bp := range current {
+		if bp.ID <= 0 {
+			continue
+		}
+		location, ok := pd.breakpointLocations[bp.ID]
+		if !ok {
+			location = fmt.Sprintf("%s:%d", bp.File, bp.Line)
+		}
+		//a location can have more than one breakpoint, like a function that was inlined
+		if seen[location] {
+			continue
+		}
+		seen[location] = true
+		saved = append(saved, config.Breakpoint{
+			Location:    location,
+			Condition:   bp.Cond,
+			HitCount:    bp.HitCond,
+			Tracepoint:  bp.Tracepoint,
+			Expressions: bp.Variables,
+		})
+	}
+	for _, bp := range pd.unappliedBreakpoints {
+		if !seen[bp.Location] {
+			seen[bp.Location] = true
+			saved = append(saved, bp)
+		}
+	}
+	if len(saved) == 0 && len(pd.savedBreakpoints) == 0 || reflect.DeepEqual(saved, pd.savedBreakpoints) {
+		return nil
+	}
+	pd.savedBreakpoints = saved
+	fmt.Printf("Saving %d breakpoints to %s\n", len(saved), pd.BreakpointsFile)
+	return config.SaveBreakpoints(pd.BreakpointsFile, saved)
+}
@@ -0,0 +1,175 @@
+package delve
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ncsnw/skavo/pkg/dlv"
+	"github.com/ncsnw/skavo/pkg/goroutines"
+)
+
+//What to do with the process once the crash is captured
+const (
+	//Let the process carry on crashing with delve attached
+	OnCrashCrash = "crash"
+	//Detach delve and let the process crash on its own
+	OnCrashDetach = "detach"
+)
+
+//The runtime functions a process goes through when it dies of an unrecovered panic or a fatal error. Delve breaks on
+//the first two by itself, skavo makes sure they're there.
+var crashFunctions = map[string]string{
+	"runtime.fatalpanic": "panic",
+	"runtime.fatalthrow": "fatal error",
+	"runtime.fatal":      "fatal error",
+}
+
+//The state of a process that was about to crash
+type CrashReport struct {
+	Time time.Time `json:"time"`
+	Pid  int       `json:"pid"`
+	//panic or fatal error
+	Reason    string `json:"reason"`
+	Goroutine int    `json:"goroutine"`
+	//The panic value or the fatal error's message
+	Value string `json:"value"`
+	//The crashing goroutine's stack with its locals
+	Stack      []goroutines.Frame   `json:"stack"`
+	Goroutines *goroutines.Snapshot `json:"goroutines"`
+}
+
+//Keep the process running with breakpoints on the runtime's crash paths, restarting it under delve first if restart is
+//true. When it crashes, capture the crashing goroutine and every stack, then let it crash with delve attached or
+//detached depending on onCrash. Returns nil if skavo was stopped with ctrl-c first.
+func (pd *PodDelve) CatchCrash(restart bool, onCrash string) *CrashReport {
+	var report *CrashReport
+	pd.InstallDelve()
+	launched := !pd.checkPodPort()
+	if launched {
+		if restart {
+			fmt.Printf("Relaunching pid %d with delve\n", pd.Process.Pid)
+		}
+		pd.launchDelve(restart)
+	}
+	stopForward := pd.forward()
+	defer close(stopForward)
+	client, err := dlv.Connect(pd.localAddr())
+	if err != nil {
+		//the process stays stopped under the launched delve otherwise
+		if launched {
+			_, _ = pd.Agent.StopDelve()
+		}
+		panic(err)
+	}
+	_, err = halt(client)
+	var crashes map[int]string
+	var captureErr error
+	if err == nil {
+		crashes, err = crashBreakpoints(client)
+	}
+	if err == nil {
+		fmt.Println("Waiting for the process to crash, press ctrl-c to stop")
+		_, err = continueUntil(client, client.Continue, func(state *dlv.DebuggerState) bool {
+			for _, thread := range state.Threads {
+				if thread.Breakpoint == nil || crashes[thread.Breakpoint.ID] == "" {
+					continue
+				}
+				//a restarted process has a new pid
+				report, captureErr = captureCrash(client, state.Pid, thread, crashes[thread.Breakpoint.ID])
+				return true
+			}
+			return false
+		})
+	}
+	err = firstErr(err, captureErr)
+	switch {
+	case err != nil:
+		if launched {
+			_ = client.Detach(false)
+		} else {
+			_ = client.Close()
+		}
+		panic(fmt.Errorf("failed to catch a crash of pid %d: %+v", pd.Process.Pid, err))
+	case report != nil && onCrash == OnCrashDetach:
+		fmt.Println("Detaching delve, the process will crash")
+		err = client.Detach(false)
+	case report != nil:
+		fmt.Println("Letting the process crash")
+		err = client.ContinueAndClose()
+	case launched:
+		err = client.Detach(false)
+	default:
+		err = client.ContinueAndClose()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to release the process: %+v\n", err)
+	}
+	return report
+}
+
+//Make sure there's a breakpoint on each crash path the binary has, returns the breakpoint ids with the crash reason
+func crashBreakpoints(client *dlv.Client) (map[int]string, error) {
+	existing, err := client.ListBreakpoints()
+	if err != nil {
+		return nil, err
+	}
+	crashes := make(map[int]string)
+	for _, bp := range existing {
+		if reason, ok := crashFunctions[bp.FunctionName]; ok {
+			crashes[bp.ID] = reason
+		}
+	}
+	for function, reason := range crashFunctions {
+		covered := false
+		for _, bp := range existing {
+			covered = covered || bp.FunctionName == function
+		}
+		if covered {
+			continue
+		}
+		bp, err := client.CreateBreakpoint(&dlv.Breakpoint{FunctionName: function, Line: -1})
+		if err != nil {
+			//not every go version has every function
+			continue
+		}
+		crashes[bp.ID] = reason
+	}
+	if len(crashes) == 0 {
+		return nil, fmt.Errorf("couldn't set a breakpoint on any of the runtime's crash paths")
+	}
+	return crashes, nil
+}
+
+func captureCrash(client *dlv.Client, pid int, thread *dlv.Thread, reason string) (*CrashReport, error) {
+	fmt.Printf("Caught a %s in goroutine %d, capturing the state\n", reason, thread.GoroutineID)
+	report := &CrashReport{Time: time.Now(), Pid: pid, Reason: reason, Goroutine: thread.GoroutineID}
+	stack, err := client.Stacktrace(thread.GoroutineID, 50, &dlv.DefaultLoadConfig)
+	if err != nil {
+		return nil, err
+	}
+	report.Stack = goroutines.Stack(stack)
+	report.Value = crashValue(client, thread, reason, stack)
+	report.Goroutines, err = goroutines.Collect(client, pid, nil)
+	return report, err
+}
+
+//The panic value, or the message runtime.throw or runtime.fatal was called with
+func crashValue(client *dlv.Client, thread *dlv.Thread, reason string, stack []dlv.Stackframe) string {
+	scope := dlv.EvalScope{GoroutineID: thread.GoroutineID}
+	expr := "runtime.curg._panic.arg"
+	if reason != "panic" {
+		expr = "s"
+		for i, frame := range stack {
+			if frame.Function != nil && (frame.Function.Name == "runtime.throw" || frame.Function.Name == "runtime.fatal") {
+				scope.Frame = i
+				break
+			}
+		}
+	}
+	v, err := client.Eval(scope, expr, &dlv.DefaultLoadConfig)
+	if err != nil {
+		return fmt.Sprintf("(couldn't read %s: %v)", expr, err)
+	}
+	return v.String()
+}
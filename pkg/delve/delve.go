@@ -4,9 +4,8 @@ import (
 	"bytes"
 	"context"
 	"fmt"
-	"io"
+	"net"
 	"os"
-	"strconv"
 	"strings"
 	"time"
 
@@ -22,8 +21,11 @@ import (
 
 	"github.com/narcolepticsnowman/go-mirror/mirror"
 
+	"github.com/ncsnw/skavo/pkg/agent"
+	"github.com/ncsnw/skavo/pkg/agent/protocol"
+	"github.com/ncsnw/skavo/pkg/config"
 	"github.com/ncsnw/skavo/pkg/k8s"
-	"github.com/ncsnw/skavo/pkg/util"
+	"github.com/ncsnw/skavo/pkg/target"
 )
 
 const (
@@ -36,6 +38,7 @@ const (
 	skavoClusterRole        = "skavo-cluster-role"
 	skavoClusterRoleBinding = "skavo-cluster-role-binding"
 	skavoEntrypointShName   = "/skavoEntrypoint.sh"
+	skavoDir                = protocol.Dir
 )
 
 type PodDelve struct {
@@ -44,41 +47,127 @@ type PodDelve struct {
 	ContainerName string
 	Process       k8s.ContainerProcess
 	Client        *k8s.Client
-	LocalPort     string
-	PodPort       string
+	//Where delve is installed and run, Relaunch is only supported for pod targets
+	Target target.Target
+	//The agent in the target, which installs and runs delve
+	Agent *agent.Agent
+	//The address to bind the local port on
+	Address string
+	//The local port to forward to the pod port, 0 or AutoPort picks a free one
+	LocalPort string
+	PodPort   string
+	//Extra flags to pass to dlv when attaching or restarting
+	DlvFlags []string
+	//Pin the delve release instead of choosing one that supports the process's go version
+	DelveVersion string
+	//What to do with the process when skavo exits: OnExitDetach, OnExitContinue or OnExitKill
+	OnExit string
+	//Remove the installed tooling when skavo exits
+	Cleanup bool
+	//The breakpoints to set once delve is reachable and save back on exit, empty to not keep any
+	BreakpointsFile string
+	toolchain       *Toolchain
+	//The locations the breakpoints from the file were set with, by breakpoint id
+	breakpointLocations map[int]string
+	//The breakpoints from the file that couldn't be set, they're saved back as they were
+	unappliedBreakpoints []config.Breakpoint
+	//The breakpoints last loaded from or saved to the file
+	savedBreakpoints []config.Breakpoint
+	//Stops keepSavingBreakpoints, nil if it isn't running
+	stopSavingBreakpoints func()
+}
+
+//The local target uses the dlv on the PATH
+func (pd *PodDelve) isLocal() bool {
+	_, ok := pd.Target.(*target.Local)
+	return ok
+}
+
+func (pd *PodDelve) dlvPath() string {
+	if pd.isLocal() {
+		return "dlv"
+	}
+	return pd.toolchain.DlvPath()
 }
 
 func (pd *PodDelve) InstallDelve() {
+	if pd.isLocal() {
+		return
+	}
+	if pd.toolchain == nil {
+		pd.toolchain = pd.resolveToolchain(pd.DelveVersion)
+	}
 	fmt.Println("Installing Delve...")
-	_, _, err := pd.Exec("mkdir", "-p", "/tmp/skavo")
-	util.MaybePanic(err)
-	pd.ExecWrite(strings.NewReader(installDelve), "/tmp/skavo/installDelve.sh")
-	pd.ExecWrite(strings.NewReader(doInstallDelve), "/tmp/skavo/doInstallDelve.sh")
-	pd.BgExec("nohup", "sh", "/tmp/skavo/doInstallDelve.sh")
-	util.MaybePanic(wait.PollImmediate(2*time.Second, 5*time.Minute, func() (done bool, err error) {
-		success, _, _ := pd.Exec("ls", "/tmp/skavo/installsuccess")
-		fail, _, _ := pd.Exec("ls", "/tmp/skavo/installfail")
-
-		if fail != "" {
-			return false, fmt.Errorf("delve install failed")
+	progress := newInstallProgress(os.Stdout)
+	_, err := pd.Agent.InstallDelve(protocol.InstallParams{
+		DelveVersion: pd.toolchain.DelveVersion,
+		GoVersion:    pd.toolchain.GoVersion,
+		GoArch:       pd.toolchain.GoArch,
+		GoSha256:     pd.toolchain.GoSha256,
+	}, progress)
+	if err != nil {
+		panic(fmt.Errorf("delve install failed during the %s step, the end of %s was:\n%s", progress.step, protocol.InstallLog, progress.tail()))
+	}
+}
+
+//Start delve with the agent, attaching to the process or restarting it under delve
+func (pd *PodDelve) launchDelve(restart bool) {
+	caps := pd.Agent.Capabilities()
+	if !caps.SysPtrace && caps.Uid != 0 {
+		fmt.Println("The container doesn't have CAP_SYS_PTRACE and isn't running as root, delve may not be able to attach")
+	}
+	status, err := pd.Agent.Launch(protocol.LaunchParams{
+		Dlv:     pd.dlvPath(),
+		Port:    pd.PodPort,
+		Flags:   pd.DlvFlags,
+		Pid:     pd.Process.Pid,
+		Restart: restart,
+		Command: pd.Process.Command,
+	})
+	if err != nil {
+		//the agent's status has the end of delve's output when it got as far as starting it
+		if status, statusErr := pd.Agent.Status(); statusErr == nil && status.Log != "" {
+			panic(fmt.Errorf("failed to start delve: %+v, the end of delve's output was:\n%s", err, status.Log))
 		}
-		fmt.Println("Waiting for Delve install to finish...")
-		return success != "", nil
-	}))
+		panic(fmt.Errorf("failed to start delve: %+v", err))
+	}
+	if !status.Running {
+		panic(fmt.Errorf("delve exited with code %d: %s\n%s", status.ExitCode, status.Error, status.Log))
+	}
+	fmt.Printf("Delve is running as pid %d\n", status.DlvPid)
 }
 
 func (pd *PodDelve) ForwardPort() {
+	stopForward := pd.forward()
+	pd.applyBreakpoints()
+	pd.keepSavingBreakpoints()
+	pd.waitForExit(stopForward)
+}
+
+//Start forwarding the local port to delve, close the returned channel to stop
+func (pd *PodDelve) forward() chan struct{} {
+	if pd.Address == "" {
+		pd.Address = "127.0.0.1"
+	}
+	if pd.LocalPort == AutoPort {
+		pd.LocalPort = "0"
+	}
+	if !isLoopback(pd.Address) {
+		fmt.Printf("WARNING: binding %s, delve has no authentication and anyone who can reach this address can run code in the process\n", pd.Address)
+	}
 	fmt.Printf("Forwarding local port %s to remote port %s\n", pd.LocalPort, pd.PodPort)
-	<-pd.Client.ForwardPort(pd.Namespace, pd.PodName, pd.LocalPort, pd.PodPort)
+	stopForward, localPort := pd.Target.ForwardPort(pd.Address, pd.LocalPort, pd.PodPort)
+	pd.LocalPort = localPort
+	fmt.Printf("Delve is listening on %s\n", net.JoinHostPort(pd.Address, pd.LocalPort))
+	return stopForward
 }
 
 func (pd *PodDelve) RestartProcess() {
 	pd.InstallDelve()
-	fmt.Printf("Relaunching pid %d with delve\n", pd.Process.Pid)
-	go func() {
-		args := append([]string{pd.PodPort, strconv.Itoa(pd.Process.Pid)}, pd.Process.Command...)
-		pd.runScript(delveExec, "delveExec.sh", args...)
-	}()
+	if !pd.checkPodPort() {
+		fmt.Printf("Relaunching pid %d with delve\n", pd.Process.Pid)
+		pd.launchDelve(true)
+	}
 	pd.ForwardPort()
 }
 
@@ -151,6 +240,9 @@ func (pd *PodDelve) UpdateResource(obj runtime.Object) runtime.Object {
 }
 
 func (pd *PodDelve) Relaunch(pod *v1.Pod) {
+	pd.toolchain = pd.resolveToolchain(pd.DelveVersion)
+	//the new pod runs the same image, so check the port in the current one
+	pd.checkPodPort()
 	kind, resource := pd.getRootResource(pod)
 	pd.deployAdmissionWebhook()
 
@@ -180,6 +272,15 @@ func (pd *PodDelve) Relaunch(pod *v1.Pod) {
 			panic(fmt.Errorf("failed to get pod list: %+v", err))
 		}
 		pd.PodName = podList.Items[0].Name
+		//the agent is in the old pod
+		pd.Agent.Close()
+		pd.Agent = nil
+		pd.Target = &target.Pod{
+			Client:        pd.Client,
+			Namespace:     pd.Namespace,
+			PodName:       pd.PodName,
+			ContainerName: pd.ContainerName,
+		}
 	}
 	pd.ForwardPort()
 }
@@ -189,44 +290,42 @@ func (pd *PodDelve) addSkavoAnnotations(resource runtime.Object) {
 	annotations := meta.GetAnnotations()
 	annotations["skavo.container"] = pd.ContainerName
 	annotations["skavo.cmd"] = skavoEntrypointShName
-	annotations["skavo.args"] = "\"" + pd.PodPort + "\" \"" + strings.Join(pd.Process.Command, "\" \"") + "\""
+	args := append([]string{pd.PodPort, pd.toolchain.DelveVersion, pd.toolchain.GoVersion, pd.toolchain.GoArch, pd.toolchain.GoSha256}, pd.Process.Command...)
+	annotations["skavo.args"] = "\"" + strings.Join(args, "\" \"") + "\""
 	annotations["skavo.cfgMap"] = configMapName
 	meta.SetAnnotations(annotations)
 }
 
 func (pd *PodDelve) AttachToProcess() {
-	pd.InstallDelve()
-	fmt.Printf("Attaching to Process: %+v\n", pd.Process)
-	go func() {
-		pd.runScript(delveAttach, "delveAttach.sh", pd.PodPort, strconv.Itoa(pd.Process.Pid))
-	}()
-	pd.ForwardPort()
+	pd.waitForExit(pd.Attach())
 }
 
-func (pd *PodDelve) BgExec(cmd ...string) {
-	go func() {
-		util.MaybePanic(pd.Client.Exec(
-			pd.PodName,
-			pd.Namespace,
-			pd.ContainerName,
-			cmd,
-			k8s.ExecOptions{
-				Out:    nil,
-				In:     nil,
-				ErrOut: os.Stderr,
-			},
-		))
-
-	}()
+//Attach delve to the process, forward the port and set the breakpoints without waiting for ctrl-c. Close the returned
+//channel with Stop.
+func (pd *PodDelve) Attach() chan struct{} {
+	pd.InstallDelve()
+	if !pd.checkPodPort() {
+		fmt.Printf("Attaching to Process: %+v\n", pd.Process)
+		pd.launchDelve(false)
+	}
+	stopForward := pd.forward()
+	pd.applyBreakpoints()
+	pd.keepSavingBreakpoints()
+	return stopForward
 }
 
+//Run a command in the target, over the agent's session when there is one
 func (pd *PodDelve) Exec(cmd ...string) (string, string, error) {
+	if pd.Agent != nil {
+		result, err := pd.Agent.Exec(cmd, nil)
+		if err == nil && result.ExitCode != 0 {
+			err = fmt.Errorf("%s exited with code %d", cmd[0], result.ExitCode)
+		}
+		return string(result.Stdout), string(result.Stderr), err
+	}
 	out := bytes.NewBuffer([]byte{})
 	errOut := bytes.NewBuffer([]byte{})
-	err := pd.Client.Exec(
-		pd.PodName,
-		pd.Namespace,
-		pd.ContainerName,
+	err := pd.Target.Exec(
 		cmd,
 		k8s.ExecOptions{
 			Out:    out,
@@ -238,29 +337,6 @@ func (pd *PodDelve) Exec(cmd ...string) (string, string, error) {
 	return out.String(), errOut.String(), err
 }
 
-func (pd *PodDelve) ExecWrite(in io.Reader, fileName string) {
-	util.MaybePanic(pd.Client.Exec(
-		pd.PodName,
-		pd.Namespace,
-		pd.ContainerName,
-		[]string{"sh", "-c", "cat /dev/stdin > " + fileName},
-		k8s.ExecOptions{
-			Out:    nil,
-			In:     in,
-			ErrOut: nil,
-		},
-	))
-}
-
-func (pd *PodDelve) runScript(src string, name string, args ...string) {
-	pd.ExecWrite(strings.NewReader(src), name)
-	_, errOut, err := pd.Exec("sh", "-c", "sh /"+name+" "+strings.Join(args, " ")+" 2>&1 &")
-	if err != nil {
-		panic(fmt.Errorf("%s %+v", errOut, err))
-	}
-	util.MaybePanic(err)
-}
-
 func (pd *PodDelve) getRootResource(pod *v1.Pod) (string, runtime.Object) {
 	var root runtime.Object
 	kind := ""
@@ -0,0 +1,54 @@
+package delve
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ncsnw/skavo/pkg/dlv"
+)
+
+//Write a core dump of the process with delve and copy it and the process's executable to dir. The process is stopped
+//while the dump is written and runs again once it's done. Returns the local paths of the core and executable.
+func (pd *PodDelve) Dump(dir string) (string, string) {
+	core := fmt.Sprintf("%s/core.%d", skavoDir, pd.Process.Pid)
+	err := pd.withStoppedProcess(func(client *dlv.Client) error {
+		return pd.writeCore(client, core)
+	})
+	if err != nil {
+		panic(fmt.Errorf("failed to dump pid %d: %+v", pd.Process.Pid, err))
+	}
+	defer func() {
+		if _, errOut, err := pd.Exec("rm", "-f", core); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to remove %s: %s %+v\n", core, errOut, err)
+		}
+	}()
+	localCore := filepath.Join(dir, filepath.Base(core))
+	exe := filepath.Join(dir, fmt.Sprintf("exe.%d", pd.Process.Pid))
+	fmt.Printf("Copying the core to %s\n", localCore)
+	pd.Target.CopyFrom(core, localCore)
+	fmt.Printf("Copying the executable to %s\n", exe)
+	//the exe link reads the binary the process runs, even if the file was replaced since
+	pd.Target.CopyFrom(fmt.Sprintf("/proc/%d/exe", pd.Process.Pid), exe)
+	if err := os.Chmod(exe, 0755); err != nil {
+		panic(err)
+	}
+	return localCore, exe
+}
+
+func (pd *PodDelve) writeCore(client *dlv.Client, core string) error {
+	fmt.Printf("Writing a core dump of pid %d to %s\n", pd.Process.Pid, core)
+	dump, err := client.DumpStart(core)
+	for err == nil && !dump.AllDone {
+		if dump.ThreadsTotal > 0 {
+			fmt.Printf("\rThreads %d/%d, memory %dMi/%dMi", dump.ThreadsDone, dump.ThreadsTotal, dump.MemDone/(1024*1024), dump.MemTotal/(1024*1024))
+		}
+		dump, err = client.DumpWait(int(time.Second / time.Millisecond))
+	}
+	fmt.Println()
+	if err == nil && dump.Err != "" {
+		err = fmt.Errorf("%s", dump.Err)
+	}
+	return err
+}
@@ -0,0 +1,40 @@
+package delve
+
+import (
+	"fmt"
+
+	"github.com/ncsnw/skavo/pkg/dlv"
+)
+
+//An expression and what it evaluated to
+type EvalResult struct {
+	Expr     string        `json:"expr"`
+	Value    string        `json:"value,omitempty"`
+	Variable *dlv.Variable `json:"variable,omitempty"`
+	Err      string        `json:"error,omitempty"`
+}
+
+//Stop the process, evaluate the expressions in the scope of the goroutine and frame, -1 for the goroutine that
+//stopped, and let it run again
+func (pd *PodDelve) Eval(exprs []string, goroutine int, frame int) []EvalResult {
+	results := make([]EvalResult, 0, len(exprs))
+	err := pd.withStoppedProcess(func(client *dlv.Client) error {
+		scope := dlv.EvalScope{GoroutineID: goroutine, Frame: frame}
+		for _, expr := range exprs {
+			result := EvalResult{Expr: expr}
+			v, err := client.Eval(scope, expr, &dlv.DefaultLoadConfig)
+			if err != nil {
+				result.Err = err.Error()
+			} else {
+				result.Value = v.String()
+				result.Variable = v
+			}
+			results = append(results, result)
+		}
+		return nil
+	})
+	if err != nil {
+		panic(fmt.Errorf("failed to evaluate in pid %d: %+v", pd.Process.Pid, err))
+	}
+	return results
+}
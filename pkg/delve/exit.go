@@ -0,0 +1,96 @@
+package delve
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/ncsnw/skavo/pkg/dlv"
+	"github.com/ncsnw/skavo/pkg/k8s"
+)
+
+//What to do with the process when skavo exits
+const (
+	//Detach delve and let the process run
+	OnExitDetach = "detach"
+	//Leave delve attached and let the process run
+	OnExitContinue = "continue"
+	//Kill the process
+	OnExitKill = "kill"
+)
+
+//Wait for ctrl-c, then clear the breakpoints and let the process go, stop the port forward and optionally remove the tooling
+func (pd *PodDelve) waitForExit(stopForward chan struct{}) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	<-signals
+	//a second ctrl-c exits right away
+	signal.Stop(signals)
+	fmt.Println("Exiting...")
+	pd.Stop(stopForward)
+}
+
+//Clear the breakpoints and let the process go, stop the port forward and optionally remove the tooling
+func (pd *PodDelve) Stop(stopForward chan struct{}) {
+	if pd.stopSavingBreakpoints != nil {
+		pd.stopSavingBreakpoints()
+		pd.stopSavingBreakpoints = nil
+	}
+	if err := pd.releaseProcess(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to release the process, it may still be stopped by delve: %+v\n", err)
+	}
+	close(stopForward)
+	if pd.Agent != nil {
+		pd.Agent.Close()
+	}
+	if pd.Cleanup {
+		pd.RemoveTooling()
+	}
+}
+
+func (pd *PodDelve) releaseProcess() error {
+	client, err := dlv.Connect(pd.localAddr())
+	if err != nil {
+		return err
+	}
+	state, err := client.State()
+	if err != nil {
+		client.Close()
+		return err
+	}
+	if state.Running {
+		if _, err := client.Halt(); err != nil {
+			client.Close()
+			return err
+		}
+	}
+	if err := pd.saveBreakpoints(client); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to save the breakpoints to %s: %+v\n", pd.BreakpointsFile, err)
+	}
+	if err := client.ClearUserBreakpoints(); err != nil {
+		client.Close()
+		return err
+	}
+	switch pd.OnExit {
+	case OnExitContinue:
+		fmt.Println("Cleared breakpoints, the process is running with delve attached")
+		return client.ContinueAndClose()
+	case OnExitKill:
+		fmt.Println("Killing the process")
+		return client.Detach(true)
+	default:
+		fmt.Println("Detaching delve from the process")
+		return client.Detach(false)
+	}
+}
+
+//Remove everything skavo installed in the target, including the agent, so this doesn't go through its session
+func (pd *PodDelve) RemoveTooling() {
+	fmt.Printf("Removing %s\n", skavoDir)
+	errOut := new(bytes.Buffer)
+	if err := pd.Target.Exec([]string{"rm", "-rf", skavoDir}, k8s.ExecOptions{ErrOut: errOut}); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to remove %s: %s %+v\n", skavoDir, errOut, err)
+	}
+}
@@ -0,0 +1,104 @@
+package delve
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//Pass as the pod port to have skavo pick a free one
+const RandomPort = "random"
+
+//Pass as the local port to have skavo pick a free one, same as 0
+const AutoPort = "auto"
+
+//The range random pod ports are picked from, below the usual linux ephemeral port range
+const (
+	randomPortMin = 20000
+	randomPortMax = 32000
+)
+
+//The tcp state of a listening socket in /proc/net/tcp
+const tcpListen = "0A"
+
+func isLoopback(address string) bool {
+	if address == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(address)
+	return ip != nil && ip.IsLoopback()
+}
+
+//The address to reach the forwarded port on from this machine
+func (pd *PodDelve) localAddr() string {
+	host := pd.Address
+	if ip := net.ParseIP(host); host == "" || ip != nil && ip.IsUnspecified() {
+		host = "127.0.0.1"
+	}
+	return net.JoinHostPort(host, pd.LocalPort)
+}
+
+//Read the ports with a listening socket in the target from /proc/net/tcp and /proc/net/tcp6
+func (pd *PodDelve) listeningPorts() map[int]bool {
+	out, errOut, err := pd.Exec("sh", "-c", "cat /proc/net/tcp /proc/net/tcp6 2>/dev/null; true")
+	if err != nil {
+		panic(fmt.Errorf("failed to read /proc/net/tcp: %s %+v", errOut, err))
+	}
+	return parseListeningPorts(out)
+}
+
+func parseListeningPorts(procNetTcp string) map[int]bool {
+	ports := make(map[int]bool)
+	for _, line := range strings.Split(procNetTcp, "\n") {
+		//sl local_address rem_address st ...
+		fields := strings.Fields(line)
+		if len(fields) < 4 || fields[3] != tcpListen {
+			continue
+		}
+		local := strings.Split(fields[1], ":")
+		if len(local) != 2 {
+			continue
+		}
+		port, err := strconv.ParseInt(local[1], 16, 32)
+		if err != nil {
+			continue
+		}
+		ports[int(port)] = true
+	}
+	return ports
+}
+
+//Pick a random pod port if asked to, and make sure nothing but a delve skavo started is listening on it.
+//Returns true if a skavo delve is already listening on the port.
+func (pd *PodDelve) checkPodPort() bool {
+	listening := pd.listeningPorts()
+	if pd.PodPort == RandomPort {
+		rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+		for {
+			port := randomPortMin + rng.Intn(randomPortMax-randomPortMin)
+			if !listening[port] {
+				pd.PodPort = strconv.Itoa(port)
+				fmt.Printf("Using pod port %s\n", pd.PodPort)
+				return false
+			}
+		}
+	}
+	port, err := strconv.Atoi(pd.PodPort)
+	if err != nil {
+		panic(fmt.Errorf("invalid pod port %s", pd.PodPort))
+	}
+	if !listening[port] {
+		return false
+	}
+	listen := "--listen=127.0.0.1:" + pd.PodPort
+	for _, p := range pd.Agent.ListProcesses() {
+		if strings.Contains(strings.Join(p.Command, " "), listen) {
+			fmt.Printf("Delve is already listening on pod port %s (pid %d)\n", pd.PodPort, p.Pid)
+			return true
+		}
+	}
+	panic(fmt.Errorf("something else is already listening on pod port %s in %s, pick another -podport or use -podport %s", pd.PodPort, pd.Target, RandomPort))
+}
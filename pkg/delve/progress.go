@@ -0,0 +1,75 @@
+package delve
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ncsnw/skavo/pkg/agent/protocol"
+)
+
+const (
+	installStepPrefix = protocol.StepMarker
+	installTailLines  = 20
+)
+
+var installSteps = []struct {
+	name        string
+	description string
+}{
+	{"packages", "Installing packages"},
+	{"toolchain", "Installing the go toolchain"},
+	{"download", "Downloading delve"},
+	{"build", "Building delve"},
+}
+
+//Prints the install log as it's written, with the install steps as headings
+type installProgress struct {
+	out     io.Writer
+	partial []byte
+	step    string
+	lines   []string
+}
+
+func newInstallProgress(out io.Writer) *installProgress {
+	return &installProgress{out: out, step: "setup"}
+}
+
+func (p *installProgress) Write(b []byte) (int, error) {
+	p.partial = append(p.partial, b...)
+	for {
+		i := bytes.IndexByte(p.partial, '\n')
+		if i < 0 {
+			return len(b), nil
+		}
+		p.line(string(p.partial[:i]))
+		p.partial = p.partial[i+1:]
+	}
+}
+
+func (p *installProgress) line(line string) {
+	if strings.HasPrefix(line, installStepPrefix) {
+		p.step = strings.TrimPrefix(line, installStepPrefix)
+		for i, step := range installSteps {
+			if step.name == p.step {
+				fmt.Fprintf(p.out, "[%d/%d] %s...\n", i+1, len(installSteps), step.description)
+			}
+		}
+		return
+	}
+	p.lines = append(p.lines, line)
+	if len(p.lines) > installTailLines {
+		p.lines = p.lines[1:]
+	}
+	fmt.Fprintf(p.out, "    %s\n", line)
+}
+
+//The last lines of the log
+func (p *installProgress) tail() string {
+	lines := p.lines
+	if len(p.partial) > 0 {
+		lines = append(lines, string(p.partial))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,160 @@
+package delve
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+
+	"github.com/ncsnw/skavo/pkg/dlv"
+	"github.com/ncsnw/skavo/pkg/k8s"
+)
+
+//Run a starlark script against the process with dlv connect, which has delve's scripting support. The dlv on this
+//machine runs it through the forwarded port if there is one, otherwise the script is uploaded and run with the dlv in
+//the target. The breakpoints the script sets are cleared afterwards, and delve is detached if it was attached just for
+//the script.
+func (pd *PodDelve) RunScript(script string) {
+	source, err := ioutil.ReadFile(script)
+	if err != nil {
+		panic(fmt.Errorf("failed to read %s: %+v", script, err))
+	}
+	pd.InstallDelve()
+	launched := !pd.checkPodPort()
+	if launched {
+		pd.launchDelve(false)
+	}
+	stopForward := pd.forward()
+	defer close(stopForward)
+	if launched {
+		defer func() {
+			if err := pd.releaseProcess(); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to release the process, it may still be stopped by delve: %+v\n", err)
+			}
+		}()
+	}
+	before, running, err := pd.breakpointState()
+	if err != nil {
+		panic(fmt.Errorf("failed to list the breakpoints: %+v", err))
+	}
+	//quit -c leaves the script's breakpoints on the server, where they'd stop the process after skavo is gone
+	defer func() {
+		if err := pd.clearNewBreakpoints(before, running); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to clear the breakpoints %s set: %+v\n", script, err)
+		}
+	}()
+	if dlv, lookErr := exec.LookPath("dlv"); lookErr == nil {
+		err = pd.runScriptLocally(dlv, script)
+	} else {
+		err = pd.runScriptInTarget(filepath.Base(script), source)
+	}
+	if err != nil {
+		panic(fmt.Errorf("script %s failed: %+v", script, err))
+	}
+}
+
+//The ids of the breakpoints the user set, skipping delve's own, and whether the process is running
+func (pd *PodDelve) breakpointState() (map[int]bool, bool, error) {
+	client, err := dlv.Connect(pd.localAddr())
+	if err != nil {
+		return nil, false, err
+	}
+	defer client.Close()
+	state, err := client.State()
+	if err != nil {
+		return nil, false, err
+	}
+	ids, err := userBreakpointIDs(client)
+	return ids, state.Running, err
+}
+
+func userBreakpointIDs(client *dlv.Client) (map[int]bool, error) {
+	breakpoints, err := client.ListBreakpoints()
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[int]bool)
+	for _, bp := range breakpoints {
+		if bp.ID > 0 {
+			ids[bp.ID] = true
+		}
+	}
+	return ids, nil
+}
+
+//Clear the breakpoints that aren't in before, then let the process run if it was running before, or leave it stopped
+//for the client that stopped it
+func (pd *PodDelve) clearNewBreakpoints(before map[int]bool, running bool) error {
+	client, err := dlv.Connect(pd.localAddr())
+	if err != nil {
+		return err
+	}
+	if _, err := halt(client); err != nil {
+		client.Close()
+		return err
+	}
+	after, err := userBreakpointIDs(client)
+	if err != nil {
+		client.Close()
+		return err
+	}
+	for id := range before {
+		delete(after, id)
+	}
+	clearBreakpoints(client, after)
+	if !running {
+		return client.Close()
+	}
+	return client.ContinueAndClose()
+}
+
+//dlv connect runs the init file's commands, source runs the script and quit -c leaves the process running
+func initFile(script string) []byte {
+	return []byte(fmt.Sprintf("source %s\nquit -c\n", script))
+}
+
+func (pd *PodDelve) runScriptLocally(dlv string, script string) error {
+	abs, err := filepath.Abs(script)
+	if err != nil {
+		return err
+	}
+	init, err := ioutil.TempFile("", "skavo-init-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(init.Name())
+	if _, err := init.Write(initFile(abs)); err != nil {
+		return err
+	}
+	if err := init.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("Running %s with %s\n", script, dlv)
+	cmd := exec.Command(dlv, "connect", pd.localAddr(), "--init", init.Name())
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+func (pd *PodDelve) runScriptInTarget(name string, source []byte) error {
+	dir := path.Join(skavoDir, "scripts")
+	remote := path.Join(dir, name)
+	init := remote + ".init"
+	if _, errOut, err := pd.Exec("mkdir", "-p", dir); err != nil {
+		return fmt.Errorf("failed to create %s: %s %w", dir, errOut, err)
+	}
+	if err := pd.Agent.WriteFile(remote, source, 0644); err != nil {
+		return err
+	}
+	if err := pd.Agent.WriteFile(init, initFile(remote), 0644); err != nil {
+		return err
+	}
+	fmt.Printf("dlv isn't on your PATH, running %s with the dlv in %s\n", name, pd.Target)
+	return pd.Target.Exec(
+		[]string{pd.dlvPath(), "connect", "127.0.0.1:" + pd.PodPort, "--init", init},
+		k8s.ExecOptions{Out: os.Stdout, ErrOut: os.Stderr},
+	)
+}
@@ -1,98 +1,78 @@
 package delve
 
 const (
+	//The relaunch entrypoint runs before skavo can copy its agent into the new pod, so it installs delve with the shell.
+	//expects DELVE_VERSION, GO_VERSION, GO_ARCH and optionally GO_SHA256 to be set
 	installDelve = `
 #!/bin/sh
 set -e
 check(){
-	which $1 2>&1 >/dev/null
+	which $1 >/dev/null 2>&1
 }
-if [ -z "$GOPATH" ]; then
-	export GOPATH=/go
-fi
-if [ -f $GOPATH/bin/dlv ];then
+skavo=/tmp/skavo
+dlv=$skavo/dlv-$DELVE_VERSION
+if [ -f $dlv ];then
 	echo "Delve Already Installed"
 else
-	if ! check git || ! check wget || ! check ssh ; then
+	echo "skavo-step: packages"
+	if ! check wget || ! check sha256sum ; then
 		if check apk; then
-			apk add --no-cache git wget openssh >/dev/null 2>&1
+			apk add --no-cache -q wget ca-certificates
 		elif check apt-get; then
-			apt-get update && apt-get install -y git wget openssh >/dev/null 2>&1
+			apt-get -qq update && apt-get install -qq -y wget ca-certificates coreutils
 		elif check dnf; then
-			dnf -y install git wget openssh >/dev/null 2>&1
+			dnf -q -y install wget ca-certificates coreutils
 		elif check yum; then
-			yum update && yum install -y git wget openssh >/dev/null 2>&1
+			yum -q install -y wget ca-certificates coreutils
 		else
 			echo "Can't install requirements'"
 			exit 1
 		fi
 	fi
-	if ! check go; then
-		if check apk ; then
-		  #alpine is special and the regular linux binaries don't work
-		  apk update && apk add --no-cache go > /dev/null 2>&1
-		  go version
-		else
-			if [ ! -f /usr/lib/go/bin/go ]; then
-				wget -qO - https://dl.google.com/go/go1.16.linux-amd64.tar.gz | tar -xz -C /usr/lib
-			fi
-			if [ ! -f /bin/go ]; then
-				ln -s /usr/lib/go/bin/go /bin/go
-			fi
-			go version
+	if check apk; then
+		#alpine is special and the regular linux binaries need glibc
+		apk add --no-cache -q libc6-compat
+	fi
+	echo "skavo-step: toolchain"
+	goroot=$skavo/$GO_VERSION/go
+	if [ ! -f $goroot/bin/go ]; then
+		archive=$GO_VERSION.linux-$GO_ARCH.tar.gz
+		wget -qO $skavo/$archive https://dl.google.com/go/$archive
+		if [ -z "$GO_SHA256" ]; then
+			GO_SHA256=$(wget -qO - https://dl.google.com/go/$archive.sha256)
 		fi
+		echo "$GO_SHA256  $skavo/$archive" | sha256sum -c -
+		mkdir -p $skavo/$GO_VERSION
+		tar -xzf $skavo/$archive -C $skavo/$GO_VERSION
+		rm $skavo/$archive
 	fi
-	mkdir -p $GOPATH/src/github.com/go-delve
-	cd $GOPATH/src/github.com/go-delve
-	git clone https://github.com/go-delve/delve
-	cd delve/cmd/dlv
-	go build
-	mkdir -p $GOPATH/bin
-	mv dlv $GOPATH/bin/dlv
+	$goroot/bin/go version
+	export GOROOT=$goroot GOPATH=$skavo/gopath GOCACHE=$skavo/gocache GO111MODULE=on GOFLAGS=-mod=mod GOTOOLCHAIN=local CGO_ENABLED=0
+	build=$skavo/build-$DELVE_VERSION
+	rm -rf $build && mkdir -p $build && cd $build
+	$goroot/bin/go mod init skavo-dlv
+	echo "skavo-step: download"
+	#module downloads are verified against the go checksum database
+	$goroot/bin/go get github.com/go-delve/delve@$DELVE_VERSION
+	echo "skavo-step: build"
+	$goroot/bin/go build -o $dlv github.com/go-delve/delve/cmd/dlv
+	cd / && rm -rf $build
 fi
 `
-	doInstallDelve = `
-#!/bin/sh
-rm /tmp/skavo/installsuccess /tmp/skavo/installfail 2>/dev/null
-if sh /tmp/skavo/installDelve.sh > /tmp/skavo/install.log 2>&1 ;then
-	touch /tmp/skavo/installsuccess
-else
-	touch /tmp/skavo/installfail
-fi
-`
-	skavoEntrypoint = installDelve + `
+	skavoEntrypoint = `
 port=$1
-shift
+export DELVE_VERSION=$2 GO_VERSION=$3 GO_ARCH=$4 GO_SHA256=$5
+shift 5
+mkdir -p /tmp/skavo
+` + installDelve + `
 echo "Skavo Starting: $@"
-delve=$(which dlv 2>/dev/null || echo $GOPATH/bin/dlv)
-$delve --headless --listen=:$port --api-version=2 --accept-multiclient exec "$@" 2>&1 </dev/null  &
-`
-	delveAttach = `
-#!/bin/sh
-if [ -z "$GOPATH" ]; then
-	export GOPATH=/go
-fi
-find $GOPATH
-if ! ps -ef |grep -v grep|grep -q "$GOPATH/bin/dlv --headless"  ; then
-	$GOPATH/bin/dlv --headless --listen=:$1 --api-version=2 --accept-multiclient attach $2 2>&1 &
-else
-	echo "Delve already attached"
-fi
-`
-	delveExec = `
-#!/bin/sh
-if [ -z "$GOPATH" ]; then
-	export GOPATH=/go
-fi
-if ! ps -ef |grep -v grep|grep -q "$GOPATH/bin/dlv --headless"  ; then
-	port=$1
-	pid=$2
-	shift 2
-	echo "Restarting: $pid, $@"
-	kill $pid
-	$delve --headless --listen=:$port --api-version=2 --accept-multiclient exec "$@" 2>&1 </dev/null  &
-else
-	echo "Delve already attached"
+bin=$1
+shift
+hex=$(printf '%04X' $port)
+if cat /proc/net/tcp /proc/net/tcp6 2>/dev/null | awk -v p=":$hex" '$4 == "0A" && substr($2, length($2) - 4) == p {found=1} END {exit !found}'; then
+	echo "Skavo: port $port is already in use, not starting delve"
+	exec "$bin" "$@"
 fi
+$dlv --headless --listen=127.0.0.1:$port --api-version=2 --accept-multiclient exec "$bin" -- "$@" 2>&1 </dev/null  &
 `
 )
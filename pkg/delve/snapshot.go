@@ -0,0 +1,48 @@
+package delve
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ncsnw/skavo/pkg/dlv"
+	"github.com/ncsnw/skavo/pkg/goroutines"
+)
+
+//Stop the process just long enough to read every goroutine and its stack, with the arguments and locals loaded with
+//cfg if it isn't nil
+func (pd *PodDelve) Snapshot(cfg *dlv.LoadConfig) *goroutines.Snapshot {
+	return pd.Snapshots(1, 0, cfg)[0]
+}
+
+//Take count snapshots interval apart, letting the process run in between
+func (pd *PodDelve) Snapshots(count int, interval time.Duration, cfg *dlv.LoadConfig) []*goroutines.Snapshot {
+	snapshots := make([]*goroutines.Snapshot, 0, count)
+	err := pd.withStoppedProcess(func(client *dlv.Client) error {
+		for i := 0; i < count; i++ {
+			if i > 0 {
+				fmt.Printf("Waiting %s for the next snapshot\n", interval)
+				stopped := client.ContinueAsync()
+				time.Sleep(interval)
+				if _, err := client.Halt(); err != nil {
+					return err
+				}
+				if err := <-stopped; err != nil {
+					return err
+				}
+			}
+			fmt.Printf("Reading the goroutines of pid %d\n", pd.Process.Pid)
+			start := time.Now()
+			snapshot, err := goroutines.Collect(client, pd.Process.Pid, cfg)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Read %d goroutines in %s\n", len(snapshot.Goroutines), time.Since(start).Round(time.Millisecond))
+			snapshots = append(snapshots, snapshot)
+		}
+		return nil
+	})
+	if err != nil {
+		panic(fmt.Errorf("failed to snapshot pid %d: %+v", pd.Process.Pid, err))
+	}
+	return snapshots
+}
@@ -0,0 +1,102 @@
+package delve
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/ncsnw/skavo/pkg/dlv"
+)
+
+//Attach delve to the process if it isn't already, stop the process, and run inspect with a client connected to delve.
+//Afterwards the process runs again if it was running before, and delve is detached if it was attached just for this.
+func (pd *PodDelve) withStoppedProcess(inspect func(client *dlv.Client) error) error {
+	pd.InstallDelve()
+	launched := !pd.checkPodPort()
+	if launched {
+		pd.launchDelve(false)
+	}
+	stopForward := pd.forward()
+	defer close(stopForward)
+	client, err := dlv.Connect(pd.localAddr())
+	if err != nil {
+		//delve stops the process it attaches to, nothing would let it go without a client
+		if launched {
+			_, stopErr := pd.Agent.StopDelve()
+			return firstErr(err, stopErr)
+		}
+		return err
+	}
+	halted, err := halt(client)
+	if err == nil {
+		err = inspect(client)
+	}
+	//let the process go before anything else
+	if launched {
+		return firstErr(err, client.Detach(false))
+	}
+	//another client, like an IDE, stopped it on purpose
+	if !halted {
+		return firstErr(err, client.Close())
+	}
+	return firstErr(err, client.ContinueAndClose())
+}
+
+//Stop the process, returns whether it was running
+func halt(client *dlv.Client) (bool, error) {
+	state, err := client.State()
+	if err != nil {
+		return false, err
+	}
+	if state.Running {
+		_, err = client.Halt()
+	}
+	return state.Running, err
+}
+
+//Keep the process running with resume, calling stopped each time it stops, until stopped returns true or ctrl-c. Leaves
+//the process stopped and returns true if it was interrupted with ctrl-c.
+func continueUntil(client *dlv.Client, resume func() (*dlv.DebuggerState, error), stopped func(state *dlv.DebuggerState) bool) (bool, error) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	done := make(chan struct{})
+	defer close(done)
+	var interrupted int32
+	go func() {
+		select {
+		case <-signals:
+			atomic.StoreInt32(&interrupted, 1)
+			if _, err := client.Halt(); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to stop the process: %+v\n", err)
+			}
+		case <-done:
+		}
+	}()
+	for {
+		state, err := resume()
+		if err != nil {
+			return false, err
+		}
+		if state.Exited {
+			return false, fmt.Errorf("the process exited with status %d", state.ExitStatus)
+		}
+		if stopped(state) {
+			return false, nil
+		}
+		if atomic.LoadInt32(&interrupted) == 1 {
+			return true, nil
+		}
+	}
+}
+
+func firstErr(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package delve
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ncsnw/skavo/pkg/dlv"
+)
+
+//A tracepoint being hit
+type TraceHit struct {
+	Time      time.Time    `json:"time"`
+	Goroutine int          `json:"goroutine"`
+	Function  string       `json:"function"`
+	File      string       `json:"file"`
+	Line      int          `json:"line"`
+	Values    []TraceValue `json:"values,omitempty"`
+	//For traced functions, the arguments of a call, or the return values when Return is true
+	Arguments    []TraceValue `json:"arguments,omitempty"`
+	Return       bool         `json:"return,omitempty"`
+	ReturnValues []TraceValue `json:"returnValues,omitempty"`
+}
+
+type TraceValue struct {
+	Expr  string `json:"expr"`
+	Value string `json:"value"`
+}
+
+//Set tracepoints at the locations that evaluate exprs each time they're hit without stopping the process, and pass
+//the hits to hit until ctrl-c. The tracepoints are cleared afterwards.
+func (pd *PodDelve) Trace(locations []string, exprs []string, hit func(TraceHit)) {
+	pd.trace(func(client *dlv.Client, created map[int]bool) error {
+		for _, loc := range locations {
+			found, err := client.FindLocation(loc)
+			if err != nil {
+				return fmt.Errorf("failed to find %s: %w", loc, err)
+			}
+			for _, l := range found {
+				bp, err := client.CreateBreakpoint(&dlv.Breakpoint{Addr: l.PC, Addrs: l.PCs, Tracepoint: true, Variables: exprs})
+				if err != nil {
+					return fmt.Errorf("failed to set a tracepoint at %s: %w", loc, err)
+				}
+				created[bp.ID] = true
+				fmt.Printf("Tracing %s:%d\n", bp.File, bp.Line)
+			}
+		}
+		return nil
+	}, false, hit)
+}
+
+//Trace the calls and returns of the functions matching the regex pattern with their arguments and return values, like
+//dlv trace, until ctrl-c
+func (pd *PodDelve) TraceFunctions(pattern string, hit func(TraceHit)) {
+	pd.trace(func(client *dlv.Client, created map[int]bool) error {
+		functions, err := client.ListFunctions(pattern)
+		if err != nil {
+			return fmt.Errorf("failed to list the functions matching %s: %w", pattern, err)
+		}
+		if len(functions) == 0 {
+			return fmt.Errorf("no functions match %s", pattern)
+		}
+		fmt.Printf("Tracing %d functions\n", len(functions))
+		for _, function := range functions {
+			bp, err := client.CreateBreakpoint(&dlv.Breakpoint{FunctionName: function, Line: -1, Tracepoint: true, LoadArgs: &dlv.ShortLoadConfig})
+			if err != nil {
+				//some functions can't have breakpoints, like ones that were inlined everywhere
+				fmt.Fprintf(os.Stderr, "Not tracing %s: %+v\n", function, err)
+				continue
+			}
+			created[bp.ID] = true
+			returns, err := client.FunctionReturnLocations(function)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Not tracing the returns of %s: %+v\n", function, err)
+				continue
+			}
+			for _, addr := range returns {
+				bp, err := client.CreateBreakpoint(&dlv.Breakpoint{Addr: addr, Line: -1, Tracepoint: true, TraceReturn: true, LoadArgs: &dlv.ShortLoadConfig})
+				if err != nil {
+					return fmt.Errorf("failed to trace the return of %s: %w", function, err)
+				}
+				created[bp.ID] = true
+			}
+		}
+		return nil
+	}, true, hit)
+}
+
+//Set the tracepoints with set, which records the ids of the ones it creates, and trace them until ctrl-c. returns loads
+//the return values at the tracepoints that trace returns.
+func (pd *PodDelve) trace(set func(client *dlv.Client, created map[int]bool) error, returns bool, hit func(TraceHit)) {
+	err := pd.withStoppedProcess(func(client *dlv.Client) error {
+		created := make(map[int]bool)
+		defer clearBreakpoints(client, created)
+		if err := set(client, created); err != nil {
+			return err
+		}
+		fmt.Println("Press ctrl-c to stop tracing")
+		return traceUntilInterrupted(client, created, returns, hit)
+	})
+	if err != nil {
+		panic(fmt.Errorf("failed to trace pid %d: %+v", pd.Process.Pid, err))
+	}
+}
+
+//Keep the process running and pass on the hits of the tracepoints until ctrl-c, leaving the process stopped
+func traceUntilInterrupted(client *dlv.Client, tracepoints map[int]bool, returns bool, hit func(TraceHit)) error {
+	resume := client.Continue
+	if returns {
+		resume = client.ContinueWithReturns
+	}
+	_, err := continueUntil(client, resume, func(state *dlv.DebuggerState) bool {
+		for _, thread := range state.Threads {
+			bp := thread.Breakpoint
+			if bp == nil {
+				continue
+			}
+			if !tracepoints[bp.ID] {
+				fmt.Printf("Stopped at breakpoint %d at %s:%d, continuing\n", bp.ID, bp.File, bp.Line)
+				continue
+			}
+			hit(traceHit(thread))
+		}
+		return false
+	})
+	return err
+}
+
+func traceHit(thread *dlv.Thread) TraceHit {
+	h := TraceHit{Time: time.Now(), Goroutine: thread.GoroutineID, File: thread.File, Line: thread.Line}
+	if thread.Function != nil {
+		h.Function = thread.Function.Name
+	}
+	if thread.Breakpoint.TraceReturn {
+		h.Return = true
+		h.ReturnValues = traceValues(thread.ReturnValues)
+	}
+	if thread.BreakpointInfo != nil && thread.Breakpoint.LoadArgs != nil && !h.Return {
+		h.Arguments = traceValues(thread.BreakpointInfo.Arguments)
+	}
+	if thread.BreakpointInfo != nil {
+		//the values come in the order of the breakpoint's expressions, their names aren't the expressions
+		for i, v := range thread.BreakpointInfo.Variables {
+			expr := v.Name
+			if i < len(thread.Breakpoint.Variables) {
+				expr = thread.Breakpoint.Variables[i]
+			}
+			h.Values = append(h.Values, TraceValue{expr, v.String()})
+		}
+	}
+	return h
+}
+
+func traceValues(vars []dlv.Variable) []TraceValue {
+	values := make([]TraceValue, 0, len(vars))
+	for _, v := range vars {
+		values = append(values, TraceValue{v.Name, v.String()})
+	}
+	return values
+}
+
+func clearBreakpoints(client *dlv.Client, ids map[int]bool) {
+	for id := range ids {
+		if err := client.ClearBreakpoint(id); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to clear breakpoint %d: %+v\n", id, err)
+		}
+	}
+}
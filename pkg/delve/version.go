@@ -0,0 +1,139 @@
+package delve
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ncsnw/skavo/pkg/buildinfo"
+)
+
+//The newest delve release that supports each go 1.x minor version and builds with that go version
+var delveReleases = map[int]string{
+	13: "v1.5.1",
+	14: "v1.6.1",
+	15: "v1.6.1",
+	16: "v1.8.3",
+	17: "v1.9.1",
+	18: "v1.20.2",
+	19: "v1.21.2",
+	20: "v1.21.2",
+	21: "v1.24.2",
+	22: "v1.25.2",
+	23: "v1.25.2",
+	24: "v1.26.2",
+	25: "v1.27.2",
+	26: "v1.27.2",
+	27: "v1.27.2",
+}
+
+//The go toolchain and delve release to install in the target
+type Toolchain struct {
+	//The go version the process was built with, delve is built with the same version
+	GoVersion string
+	GoArch    string
+	//The checksum of the go archive, empty if it couldn't be fetched from go.dev, then the archive isn't downloaded
+	GoSha256     string
+	DelveVersion string
+}
+
+func (t *Toolchain) DlvPath() string {
+	return skavoDir + "/dlv-" + t.DelveVersion
+}
+
+//Read the go version of the process's binary and choose a delve release for it. Pins delveVersion if it isn't empty.
+func (pd *PodDelve) resolveToolchain(delveVersion string) *Toolchain {
+	goVersion, goArch, err := buildinfo.ReadGoVersion(pd.Agent.Open(fmt.Sprintf("/proc/%d/exe", pd.Process.Pid)))
+	if err != nil {
+		panic(fmt.Errorf("failed to read the go version of pid %d: %+v", pd.Process.Pid, err))
+	}
+	fmt.Printf("Process was built with %s for %s\n", goVersion, goArch)
+	if delveVersion == "" {
+		delveVersion = delveReleaseFor(goVersion)
+	}
+	fmt.Printf("Using delve %s\n", delveVersion)
+	sha256, err := goArchiveSha256(goVersion, goArch)
+	if err != nil {
+		panic(err)
+	}
+	return &Toolchain{
+		GoVersion:    goVersion,
+		GoArch:       goArch,
+		GoSha256:     sha256,
+		DelveVersion: delveVersion,
+	}
+}
+
+func delveReleaseFor(goVersion string) string {
+	minor, err := goMinor(goVersion)
+	if err != nil {
+		panic(err)
+	}
+	if release, ok := delveReleases[minor]; ok {
+		return release
+	}
+	newest := 0
+	for m := range delveReleases {
+		if m > newest {
+			newest = m
+		}
+	}
+	if minor < newest {
+		panic(fmt.Errorf("%s is older than any go version skavo can install delve for", goVersion))
+	}
+	fmt.Printf("%s is newer than the go versions skavo knows delve releases for, use -delve-version to pick one\n", goVersion)
+	return delveReleases[newest]
+}
+
+//The minor version of a go release like go1.21.3, go1.22rc1 or go1.21beta1. Development builds have none, and there's
+//no toolchain to download for them either.
+func goMinor(goVersion string) (int, error) {
+	parts := strings.SplitN(strings.TrimPrefix(goVersion, "go"), ".", 3)
+	if len(parts) < 2 || parts[0] != "1" {
+		return 0, fmt.Errorf("the process was built with %s, a development build of go, skavo can only install delve for go releases", goVersion)
+	}
+	digits := strings.IndexFunc(parts[1], func(r rune) bool { return r < '0' || r > '9' })
+	if digits < 0 {
+		digits = len(parts[1])
+	}
+	minor, err := strconv.Atoi(parts[1][:digits])
+	if err != nil {
+		return 0, fmt.Errorf("the process was built with %s, a development build of go, skavo can only install delve for go releases", goVersion)
+	}
+	return minor, nil
+}
+
+//Look up the archive checksum on go.dev, empty if go.dev can't be reached, in which case the agent can only use a
+//toolchain that's already installed. Fails if go.dev doesn't list an archive for the go version.
+func goArchiveSha256(goVersion string, goArch string) (string, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get("https://go.dev/dl/?mode=json&include=all")
+	if err != nil {
+		fmt.Printf("Couldn't fetch go release checksums from go.dev, the go toolchain can only be used if it's installed already: %+v\n", err)
+		return "", nil
+	}
+	defer resp.Body.Close()
+	var releases []struct {
+		Version string
+		Files   []struct {
+			Filename string
+			Sha256   string
+		}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&releases); err != nil {
+		fmt.Printf("Couldn't read go release checksums from go.dev, the go toolchain can only be used if it's installed already: %+v\n", err)
+		return "", nil
+	}
+	archive := fmt.Sprintf("%s.linux-%s.tar.gz", goVersion, goArch)
+	for _, release := range releases {
+		for _, file := range release.Files {
+			if file.Filename == archive {
+				return file.Sha256, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("go.dev has no linux/%s archive for %s, the process's go version", goArch, goVersion)
+}
@@ -0,0 +1,69 @@
+package delve
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ncsnw/skavo/pkg/k8s"
+)
+
+//How often the processes are listed while waiting for one to start
+const waitPollInterval = 100 * time.Millisecond
+
+//Wait for a matching process that wasn't running when skavo started waiting and make it the process to debug. If a
+//matching process is already running, delve is installed for its binary first so attaching to the new one is quicker.
+func (pd *PodDelve) WaitForProcess(matches func(process k8s.ContainerProcess) bool) {
+	//only matching processes are remembered, a pid that didn't match may exec the binary later
+	seen := make(map[string]bool)
+	var running []k8s.ContainerProcess
+	for _, process := range pd.Agent.ListProcesses() {
+		if matches(process) {
+			seen[processKey(process)] = true
+			running = append(running, process)
+		}
+	}
+	var prepared string
+	if len(running) > 0 {
+		fmt.Printf("%d matching processes are already running, waiting for a new one\n", len(running))
+		prepared = pd.prepareDelve(running[0])
+	} else if !pd.isLocal() {
+		fmt.Println("No matching process is running to install delve for ahead of time. It's installed once the process " +
+			"starts, which can take minutes for a new go version, and the process runs until delve attaches.")
+	}
+	fmt.Println("Waiting for a matching process to start")
+	for {
+		time.Sleep(waitPollInterval)
+		for _, process := range pd.Agent.ListProcesses() {
+			if seen[processKey(process)] || !matches(process) {
+				continue
+			}
+			fmt.Printf("Found pid %d: %v\n", process.Pid, process.Command)
+			pd.Process = process
+			//another binary may need another delve
+			if process.Exe != prepared {
+				pd.toolchain = nil
+			}
+			return
+		}
+	}
+}
+
+//Pids are reused and a process that execs is a new program, so a process is its pid, executable and command line
+func processKey(process k8s.ContainerProcess) string {
+	return fmt.Sprintf("%d %s %q", process.Pid, process.Exe, process.Command)
+}
+
+//Install delve for the process's binary, returns its executable or empty if delve couldn't be installed
+func (pd *PodDelve) prepareDelve(process k8s.ContainerProcess) (exe string) {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Fprintf(os.Stderr, "Couldn't install delve ahead of time, it will be installed once the process starts: %v\n", r)
+			pd.toolchain = nil
+			exe = ""
+		}
+	}()
+	pd.Process = process
+	pd.InstallDelve()
+	return process.Exe
+}
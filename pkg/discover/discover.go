@@ -0,0 +1,133 @@
+package discover
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/ncsnw/skavo/pkg/agent"
+	"github.com/ncsnw/skavo/pkg/buildinfo"
+	"github.com/ncsnw/skavo/pkg/k8s"
+	"github.com/ncsnw/skavo/pkg/target"
+)
+
+//A process found in a container
+type Match struct {
+	Pod       *v1.Pod
+	Container string
+	Process   k8s.ContainerProcess
+	//Empty if the process isn't a go binary
+	GoVersion string
+}
+
+//A container that couldn't be searched
+type Failure struct {
+	Pod       *v1.Pod
+	Container string
+	Err       error
+}
+
+func (f Failure) Error() string {
+	return fmt.Sprintf("%s/%s %s: %+v", f.Pod.Namespace, f.Pod.Name, f.Container, f.Err)
+}
+
+//Search the running containers of the pods for processes matching filter, searching up to concurrency containers at once
+func Find(client *k8s.Client, pods []v1.Pod, filter *regexp.Regexp, concurrency int) ([]Match, []Failure) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	matches := make([]Match, 0)
+	failures := make([]Failure, 0)
+	slots := make(chan struct{}, concurrency)
+	for i := range pods {
+		pod := &pods[i]
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.State.Running == nil {
+				continue
+			}
+			container := status.Name
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				slots <- struct{}{}
+				defer func() { <-slots }()
+				found, err := search(client, pod, container, filter)
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					failures = append(failures, Failure{pod, container, err})
+					return
+				}
+				matches = append(matches, found...)
+			}()
+		}
+	}
+	wg.Wait()
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if a.Pod.Namespace != b.Pod.Namespace {
+			return a.Pod.Namespace < b.Pod.Namespace
+		}
+		if a.Pod.Name != b.Pod.Name {
+			return a.Pod.Name < b.Pod.Name
+		}
+		if a.Container != b.Container {
+			return a.Container < b.Container
+		}
+		return a.Process.Pid < b.Process.Pid
+	})
+	return matches, failures
+}
+
+func search(client *k8s.Client, pod *v1.Pod, container string, filter *regexp.Regexp) (matches []Match, err error) {
+	//the agent panics like the rest of skavo, one container failing shouldn't stop the search
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	a := agent.New(&target.Pod{
+		Client:        client,
+		Namespace:     pod.Namespace,
+		PodName:       pod.Name,
+		ContainerName: container,
+	})
+	//searching shouldn't leave agents behind, only the container that's picked gets one to keep
+	defer func() {
+		if a.Copied {
+			a.Remove()
+		} else {
+			a.Close()
+		}
+	}()
+	for _, process := range a.ListProcesses() {
+		if !Matches(process, filter) {
+			continue
+		}
+		goVersion, _, err := buildinfo.ReadGoVersion(a.Open(fmt.Sprintf("/proc/%d/exe", process.Pid)))
+		if err != nil {
+			goVersion = ""
+		}
+		matches = append(matches, Match{pod, container, process, goVersion})
+	}
+	return matches, nil
+}
+
+//Match the filter against the command line, user or executable, a nil filter matches everything
+func Matches(process k8s.ContainerProcess, filter *regexp.Regexp) bool {
+	if filter == nil {
+		return true
+	}
+	for _, field := range []string{strings.Join(process.Command, " "), process.User, process.Exe} {
+		if filter.MatchString(field) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,220 @@
+package dlv
+
+import "fmt"
+
+//These mirror the types in github.com/go-delve/delve/service/api that skavo uses, with the same json encoding
+
+type DebuggerState struct {
+	Pid           int
+	Running       bool
+	CoreDumping   bool
+	CurrentThread *Thread `json:"currentThread,omitempty"`
+	//The goroutine selected when the process stopped
+	SelectedGoroutine *Goroutine `json:"currentGoroutine,omitempty"`
+	Threads           []*Thread
+	Exited            bool `json:"exited"`
+	ExitStatus        int  `json:"exitStatus"`
+}
+
+type Breakpoint struct {
+	//Breakpoints set by users have positive ids, delve's own breakpoints have negative ids
+	ID           int      `json:"id"`
+	Name         string   `json:"name"`
+	Addr         uint64   `json:"addr"`
+	Addrs        []uint64 `json:"addrs"`
+	File         string   `json:"file"`
+	Line         int      `json:"line"`
+	FunctionName string   `json:"functionName,omitempty"`
+	Cond         string
+	HitCond      string
+	//Continue after the breakpoint is hit
+	Tracepoint  bool     `json:"continue"`
+	TraceReturn bool     `json:"traceReturn"`
+	Goroutine   bool     `json:"goroutine"`
+	Stacktrace  int      `json:"stacktrace"`
+	Variables   []string `json:"variables,omitempty"`
+	LoadArgs    *LoadConfig
+	LoadLocals  *LoadConfig
+	HitCount    map[string]uint64 `json:"hitCount"`
+	//Total number of times the breakpoint has been hit
+	TotalHitCount uint64 `json:"totalHitCount"`
+	Disabled      bool   `json:"disabled"`
+}
+
+type Thread struct {
+	ID             int             `json:"id"`
+	PC             uint64          `json:"pc"`
+	File           string          `json:"file"`
+	Line           int             `json:"line"`
+	Function       *Function       `json:"function,omitempty"`
+	GoroutineID    int             `json:"goroutineID"`
+	Breakpoint     *Breakpoint     `json:"breakPoint,omitempty"`
+	BreakpointInfo *BreakpointInfo `json:"breakPointInfo,omitempty"`
+	ReturnValues   []Variable
+}
+
+type BreakpointInfo struct {
+	Stacktrace []Stackframe `json:"stacktrace,omitempty"`
+	Goroutine  *Goroutine   `json:"goroutine,omitempty"`
+	Variables  []Variable   `json:"variables,omitempty"`
+	Arguments  []Variable   `json:"arguments,omitempty"`
+	Locals     []Variable   `json:"locals,omitempty"`
+}
+
+type Location struct {
+	PC       uint64    `json:"pc"`
+	File     string    `json:"file"`
+	Line     int       `json:"line"`
+	Function *Function `json:"function,omitempty"`
+	PCs      []uint64  `json:"pcs,omitempty"`
+}
+
+type Stackframe struct {
+	Location
+	Locals    []Variable
+	Arguments []Variable
+	Err       string
+}
+
+type Function struct {
+	Name      string `json:"name"`
+	Value     uint64 `json:"value"`
+	Optimized bool   `json:"optimized"`
+}
+
+type Variable struct {
+	Name     string `json:"name"`
+	Addr     uint64 `json:"addr"`
+	OnlyAddr bool   `json:"onlyAddr"`
+	Type     string `json:"type"`
+	RealType string `json:"realType"`
+	Flags    uint16 `json:"flags"`
+	//A reflect.Kind
+	Kind  uint   `json:"kind"`
+	Value string `json:"value"`
+	Len   int64  `json:"len"`
+	Cap   int64  `json:"cap"`
+	//Elements, fields, or for maps alternating keys and values
+	Children   []Variable `json:"children"`
+	Base       uint64     `json:"base"`
+	Unreadable string     `json:"unreadable"`
+}
+
+//How much of a value to read from the target's memory
+type LoadConfig struct {
+	FollowPointers     bool
+	MaxVariableRecurse int
+	MaxStringLen       int
+	MaxArrayValues     int
+	//-1 reads all fields
+	MaxStructFields int
+}
+
+//The load config dlv's terminal uses
+var DefaultLoadConfig = LoadConfig{
+	FollowPointers:     true,
+	MaxVariableRecurse: 1,
+	MaxStringLen:       64,
+	MaxArrayValues:     64,
+	MaxStructFields:    -1,
+}
+
+//The load config dlv trace uses for arguments and return values
+var ShortLoadConfig = LoadConfig{
+	MaxStringLen:    64,
+	MaxStructFields: 3,
+}
+
+type Goroutine struct {
+	ID int `json:"id"`
+	//Where the goroutine is now
+	CurrentLoc Location `json:"currentLoc"`
+	//Where the goroutine is now, excluding runtime frames
+	UserCurrentLoc Location `json:"userCurrentLoc"`
+	//The go statement that started the goroutine
+	GoStatementLoc Location          `json:"goStatementLoc"`
+	StartLoc       Location          `json:"startLoc"`
+	ThreadID       int               `json:"threadID"`
+	Status         uint64            `json:"status"`
+	WaitSince      int64             `json:"waitSince"`
+	WaitReason     int64             `json:"waitReason"`
+	Unreadable     string            `json:"unreadable"`
+	Labels         map[string]string `json:"labels,omitempty"`
+}
+
+//Goroutine statuses, from the runtime
+const (
+	GoroutineIdle      = 0
+	GoroutineRunnable  = 1
+	GoroutineRunning   = 2
+	GoroutineSyscall   = 3
+	GoroutineWaiting   = 4
+	GoroutineDead      = 6
+	GoroutineCopyStack = 8
+	GoroutinePreempted = 9
+	//Set while the gc scans the stack, on top of the status
+	goroutineScan = 0x1000
+)
+
+func (g *Goroutine) StatusName() string {
+	switch g.Status &^ goroutineScan {
+	case GoroutineIdle:
+		return "idle"
+	case GoroutineRunnable:
+		return "runnable"
+	case GoroutineRunning:
+		return "running"
+	case GoroutineSyscall:
+		return "syscall"
+	case GoroutineWaiting:
+		return "waiting"
+	case GoroutineDead:
+		return "dead"
+	case GoroutineCopyStack:
+		return "copystack"
+	case GoroutinePreempted:
+		return "preempted"
+	default:
+		return fmt.Sprintf("status %d", g.Status)
+	}
+}
+
+//Why the goroutine is waiting, empty if it isn't. reasons are the names of the process's runtime, from WaitReasons,
+//since go versions number them differently.
+func (g *Goroutine) WaitReasonName(reasons []string) string {
+	if g.Status&^goroutineScan != GoroutineWaiting {
+		return ""
+	}
+	if g.WaitReason >= 0 && int(g.WaitReason) < len(reasons) {
+		return reasons[g.WaitReason]
+	}
+	return fmt.Sprintf("unknown(%d)", g.WaitReason)
+}
+
+type DebuggerCommand struct {
+	Name                 string `json:"name"`
+	ThreadID             int    `json:"threadID,omitempty"`
+	GoroutineID          int    `json:"goroutineID,omitempty"`
+	ReturnInfoLoadConfig *LoadConfig
+	Expr                 string `json:"expr,omitempty"`
+}
+
+//The goroutine and frame to evaluate an expression in
+type EvalScope struct {
+	GoroutineID  int
+	Frame        int
+	DeferredCall int
+}
+
+type DumpState struct {
+	Dumping                   bool
+	AllDone                   bool
+	ThreadsDone, ThreadsTotal int
+	MemDone, MemTotal         uint64
+	Err                       string
+}
+
+const (
+	commandContinue = "continue"
+	commandHalt     = "halt"
+)
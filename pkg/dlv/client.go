@@ -0,0 +1,213 @@
+package dlv
+
+import (
+	"fmt"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"time"
+)
+
+//A client for delve's json-rpc api (--api-version=2)
+type Client struct {
+	client *rpc.Client
+}
+
+func Connect(addr string) (*Client, error) {
+	conn, err := net.DialTimeout("tcp", addr, 10*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to delve at %s: %w", addr, err)
+	}
+	return &Client{jsonrpc.NewClient(conn)}, nil
+}
+
+func (c *Client) call(method string, args interface{}, reply interface{}) error {
+	return c.client.Call("RPCServer."+method, args, reply)
+}
+
+func (c *Client) Close() error {
+	return c.client.Close()
+}
+
+//Get the debugger state without waiting for a running process to stop
+func (c *Client) State() (*DebuggerState, error) {
+	out := struct{ State *DebuggerState }{}
+	err := c.call("State", struct{ NonBlocking bool }{true}, &out)
+	return out.State, err
+}
+
+//Stop the process
+func (c *Client) Halt() (*DebuggerState, error) {
+	out := struct{ State DebuggerState }{}
+	err := c.call("Command", DebuggerCommand{Name: commandHalt}, &out)
+	return &out.State, err
+}
+
+//Resume the process and wait for it to stop
+func (c *Client) Continue() (*DebuggerState, error) {
+	out := struct{ State DebuggerState }{}
+	err := c.call("Command", DebuggerCommand{Name: commandContinue}, &out)
+	return &out.State, err
+}
+
+//Continue, loading the return values of the functions that return at tracepoints that trace returns
+func (c *Client) ContinueWithReturns() (*DebuggerState, error) {
+	out := struct{ State DebuggerState }{}
+	err := c.call("Command", DebuggerCommand{Name: commandContinue, ReturnInfoLoadConfig: &ShortLoadConfig}, &out)
+	return &out.State, err
+}
+
+//Resume the process without waiting for it to stop, the channel gets the result once it stops
+func (c *Client) ContinueAsync() chan error {
+	stopped := make(chan error, 1)
+	go func() {
+		_, err := c.Continue()
+		stopped <- err
+	}()
+	return stopped
+}
+
+//Resume the process without waiting for it to stop, then close the connection
+func (c *Client) ContinueAndClose() error {
+	c.client.Go("RPCServer.Command", DebuggerCommand{Name: commandContinue}, &struct{ State DebuggerState }{}, nil)
+	return c.Close()
+}
+
+//Detach from the process, killing it if kill is true. The headless server exits when detached.
+func (c *Client) Detach(kill bool) error {
+	defer c.Close()
+	return c.call("Detach", struct{ Kill bool }{kill}, &struct{}{})
+}
+
+func (c *Client) ListBreakpoints() ([]*Breakpoint, error) {
+	out := struct{ Breakpoints []*Breakpoint }{}
+	err := c.call("ListBreakpoints", struct{ All bool }{false}, &out)
+	return out.Breakpoints, err
+}
+
+func (c *Client) ClearBreakpoint(id int) error {
+	return c.call("ClearBreakpoint", struct {
+		Id   int
+		Name string
+	}{id, ""}, &struct{ Breakpoint *Breakpoint }{})
+}
+
+//Clear the breakpoints set by users, delve's own panic breakpoints are kept
+func (c *Client) ClearUserBreakpoints() error {
+	breakpoints, err := c.ListBreakpoints()
+	if err != nil {
+		return err
+	}
+	for _, bp := range breakpoints {
+		if bp.ID > 0 {
+			if err := c.ClearBreakpoint(bp.ID); err != nil {
+				return fmt.Errorf("failed to clear breakpoint %d at %s:%d: %w", bp.ID, bp.File, bp.Line, err)
+			}
+		}
+	}
+	return nil
+}
+
+//Start writing a core dump of the stopped process to destination in the target
+func (c *Client) DumpStart(destination string) (*DumpState, error) {
+	out := struct{ State DumpState }{}
+	err := c.call("DumpStart", struct{ Destination string }{destination}, &out)
+	return &out.State, err
+}
+
+//Wait up to msec milliseconds for the core dump to finish
+func (c *Client) DumpWait(msec int) (*DumpState, error) {
+	out := struct{ State DumpState }{}
+	err := c.call("DumpWait", struct{ Wait int }{msec}, &out)
+	return &out.State, err
+}
+
+//List count goroutines starting from start, returns the start of the next page or -1 after the last one
+func (c *Client) ListGoroutines(start int, count int) ([]*Goroutine, int, error) {
+	out := struct {
+		Goroutines []*Goroutine
+		Nextg      int
+	}{}
+	err := c.call("ListGoroutines", struct{ Start, Count int }{start, count}, &out)
+	return out.Goroutines, out.Nextg, err
+}
+
+//List every goroutine of the stopped process
+func (c *Client) AllGoroutines() ([]*Goroutine, error) {
+	var all []*Goroutine
+	for start := 0; start >= 0; {
+		page, next, err := c.ListGoroutines(start, 1000)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, page...)
+		start = next
+	}
+	return all, nil
+}
+
+//Get up to depth frames of the goroutine's stack, with the arguments and locals loaded with cfg if it isn't nil
+func (c *Client) Stacktrace(goroutineID int, depth int, cfg *LoadConfig) ([]Stackframe, error) {
+	out := struct{ Locations []Stackframe }{}
+	err := c.call("Stacktrace", struct {
+		Id    int
+		Depth int
+		Full  bool
+		Cfg   *LoadConfig
+	}{goroutineID, depth, cfg != nil, cfg}, &out)
+	return out.Locations, err
+}
+
+//Find the addresses of a location spec like file.go:123 or a function name, as dlv's break command does
+func (c *Client) FindLocation(loc string) ([]Location, error) {
+	out := struct{ Locations []Location }{}
+	err := c.call("FindLocation", struct {
+		Scope EvalScope
+		Loc   string
+	}{EvalScope{GoroutineID: -1}, loc}, &out)
+	return out.Locations, err
+}
+
+func (c *Client) CreateBreakpoint(bp *Breakpoint) (*Breakpoint, error) {
+	out := struct{ Breakpoint Breakpoint }{}
+	err := c.call("CreateBreakpoint", struct{ Breakpoint *Breakpoint }{bp}, &out)
+	return &out.Breakpoint, err
+}
+
+//List the functions whose names match the regex filter
+func (c *Client) ListFunctions(filter string) ([]string, error) {
+	out := struct{ Funcs []string }{}
+	err := c.call("ListFunctions", struct{ Filter string }{filter}, &out)
+	return out.Funcs, err
+}
+
+//The addresses of the function's return instructions, for breakpoints that trace returns
+func (c *Client) FunctionReturnLocations(function string) ([]uint64, error) {
+	out := struct{ Addrs []uint64 }{}
+	err := c.call("FunctionReturnLocations", struct{ FnName string }{function}, &out)
+	return out.Addrs, err
+}
+
+//Evaluate the expression in the scope, loading the result with cfg
+func (c *Client) Eval(scope EvalScope, expr string, cfg *LoadConfig) (*Variable, error) {
+	out := struct{ Variable *Variable }{}
+	err := c.call("Eval", struct {
+		Scope EvalScope
+		Expr  string
+		Cfg   *LoadConfig
+	}{scope, expr, cfg}, &out)
+	return out.Variable, err
+}
+
+//Read the names of the wait reasons from the process's runtime, indexed by Goroutine.WaitReason
+func (c *Client) WaitReasons() ([]string, error) {
+	v, err := c.Eval(EvalScope{GoroutineID: -1}, "runtime.waitReasonStrings", &LoadConfig{MaxStringLen: 64, MaxArrayValues: 256})
+	if err != nil {
+		return nil, err
+	}
+	reasons := make([]string, 0, len(v.Children))
+	for _, reason := range v.Children {
+		reasons = append(reasons, reason.Value)
+	}
+	return reasons, nil
+}
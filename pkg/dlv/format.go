@@ -0,0 +1,68 @@
+package dlv
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+//Format the variable on one line, like dlv's print
+func (v Variable) String() string {
+	if v.Unreadable != "" {
+		return fmt.Sprintf("(unreadable %s)", v.Unreadable)
+	}
+	switch reflect.Kind(v.Kind) {
+	case reflect.String:
+		s := fmt.Sprintf("%q", v.Value)
+		if int64(len(v.Value)) < v.Len {
+			s += fmt.Sprintf("...+%d more", v.Len-int64(len(v.Value)))
+		}
+		return s
+	case reflect.Ptr:
+		if len(v.Children) == 0 || v.Children[0].Addr == 0 {
+			return "nil"
+		}
+		if v.Children[0].OnlyAddr {
+			return fmt.Sprintf("(%s)(%#x)", v.Type, v.Children[0].Addr)
+		}
+		return "*" + v.Children[0].String()
+	case reflect.Slice, reflect.Array:
+		return "[" + join(v.Children, v.Len) + "]"
+	case reflect.Struct:
+		fields := make([]string, 0, len(v.Children))
+		for _, field := range v.Children {
+			fields = append(fields, field.Name+": "+field.String())
+		}
+		return v.Type + " {" + strings.Join(fields, ", ") + "}"
+	case reflect.Map:
+		entries := make([]string, 0, len(v.Children)/2)
+		for i := 0; i+1 < len(v.Children); i += 2 {
+			entries = append(entries, v.Children[i].String()+": "+v.Children[i+1].String())
+		}
+		s := "[" + strings.Join(entries, ", ")
+		if more := v.Len - int64(len(entries)); more > 0 {
+			s += fmt.Sprintf(", ...+%d more", more)
+		}
+		return s + "]"
+	case reflect.Interface:
+		if len(v.Children) == 0 || v.Children[0].Kind == uint(reflect.Invalid) {
+			return "nil"
+		}
+		return v.Children[0].String()
+	}
+	if v.Value == "" && len(v.Children) > 0 {
+		return v.Type + " {" + join(v.Children, int64(len(v.Children))) + "}"
+	}
+	return v.Value
+}
+
+func join(children []Variable, length int64) string {
+	values := make([]string, 0, len(children)+1)
+	for _, child := range children {
+		values = append(values, child.String())
+	}
+	if more := length - int64(len(children)); more > 0 {
+		values = append(values, fmt.Sprintf("...+%d more", more))
+	}
+	return strings.Join(values, ", ")
+}
@@ -0,0 +1,328 @@
+package goroutines
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ncsnw/skavo/pkg/dlv"
+)
+
+//What a goroutine is blocked on
+const (
+	OpChanReceive = "chan receive"
+	OpChanSend    = "chan send"
+	OpSelect      = "select"
+	OpMutex       = "mutex"
+	OpWaitGroup   = "WaitGroup"
+	OpCond        = "sync.Cond"
+	OpIO          = "IO"
+	OpSleep       = "sleep"
+	OpSyscall     = "syscall"
+	OpRunning     = "running"
+	OpOther       = "other"
+)
+
+//The functions a goroutine blocked in an operation has on its stack, matched by prefix from the top of the stack
+var operationFunctions = []struct {
+	prefix    string
+	operation string
+}{
+	{"runtime.chanrecv", OpChanReceive},
+	{"runtime.chansend", OpChanSend},
+	{"runtime.selectgo", OpSelect},
+	//select {}
+	{"runtime.block", OpSelect},
+	{"sync.(*Mutex).lockSlow", OpMutex},
+	{"sync.(*Mutex).Lock", OpMutex},
+	{"sync.(*RWMutex).Lock", OpMutex},
+	{"sync.(*RWMutex).RLock", OpMutex},
+	{"sync.(*WaitGroup).Wait", OpWaitGroup},
+	{"sync.(*Cond).Wait", OpCond},
+	{"internal/poll.runtime_pollWait", OpIO},
+	{"time.Sleep", OpSleep},
+}
+
+//How much a group of goroutines has to grow between samples to be reported
+const growthThreshold = 10
+
+//What a goroutine is blocked on and where in the program
+type Blocked struct {
+	Operation string
+	//The channel, mutex, WaitGroup or Cond, 0 if it couldn't be read
+	Address uint64
+	//Where the program blocked, the first frame outside of the standard library
+	Location Frame
+}
+
+//Classify the goroutine, mainModule is the program's module path from the build info, empty if it isn't known
+func Classify(g Goroutine, mainModule string) Blocked {
+	b := Blocked{Operation: OpOther, Location: location(g, mainModule)}
+	switch g.Status {
+	case "running", "runnable", "preempted":
+		b.Operation = OpRunning
+		return b
+	case "syscall":
+		b.Operation = OpSyscall
+		return b
+	}
+	for i, f := range g.Stack {
+		operation := operationOf(f.Function)
+		if operation == "" {
+			continue
+		}
+		b.Operation = operation
+		//a select's channels are in the cases on its stack, the first pointer isn't one of them
+		if operation == OpSelect {
+			return b
+		}
+		//inlined frames don't always have their arguments, so look further down the same operation
+		for _, next := range g.Stack[i:] {
+			if operationOf(next.Function) != operation {
+				break
+			}
+			if b.Address = firstPointer(next.Arguments); b.Address != 0 {
+				break
+			}
+		}
+		return b
+	}
+	return b
+}
+
+func operationOf(function string) string {
+	for _, of := range operationFunctions {
+		if strings.HasPrefix(function, of.prefix) {
+			return of.operation
+		}
+	}
+	return ""
+}
+
+//The address the first pointer argument points to, the channel or lock the functions above take first
+func firstPointer(args []dlv.Variable) uint64 {
+	for _, arg := range args {
+		if reflect.Kind(arg.Kind) == reflect.Ptr && len(arg.Children) > 0 {
+			return arg.Children[0].Addr
+		}
+	}
+	return 0
+}
+
+//The first frame outside of the standard library, or outside of the runtime if there isn't one
+func location(g Goroutine, mainModule string) Frame {
+	for _, f := range g.Stack {
+		if !isStd(f.Function, mainModule) {
+			return f
+		}
+	}
+	for _, f := range g.Stack {
+		if !strings.HasPrefix(f.Function, "runtime.") {
+			return f
+		}
+	}
+	if len(g.Stack) > 0 {
+		return g.Stack[0]
+	}
+	return Frame{}
+}
+
+//Standard library import paths don't have a dot in their first element. main and the packages of the main module are
+//the exceptions, since a module path like myapp doesn't need a dot either.
+func isStd(function string, mainModule string) bool {
+	pkg := function
+	//type parameters have import paths of their own
+	if bracket := strings.Index(pkg, "["); bracket >= 0 {
+		pkg = pkg[:bracket]
+	}
+	if slash := strings.LastIndex(pkg, "/"); slash >= 0 {
+		if dot := strings.Index(pkg[slash:], "."); dot >= 0 {
+			pkg = pkg[:slash+dot]
+		}
+	} else if dot := strings.Index(pkg, "."); dot >= 0 {
+		pkg = pkg[:dot]
+	}
+	if mainModule != "" && (pkg == mainModule || strings.HasPrefix(pkg, mainModule+"/")) {
+		return false
+	}
+	first := strings.Split(pkg, "/")[0]
+	return first != "main" && !strings.Contains(first, ".")
+}
+
+//Goroutines the runtime starts for itself, like the gc workers and the finalizer
+func isSystem(g Goroutine) bool {
+	if g.CreatedBy != nil && strings.HasPrefix(g.CreatedBy.Function, "runtime.") {
+		return true
+	}
+	for _, f := range g.Stack {
+		if !strings.HasPrefix(f.Function, "runtime.") {
+			return false
+		}
+	}
+	return true
+}
+
+//Goroutines blocked on the same operation at the same place
+type BlockedGroup struct {
+	Blocked
+	Count int
+}
+
+func (b Blocked) key() string {
+	return fmt.Sprintf("%s|%x|%s:%d", b.Operation, b.Address, b.Location.File, b.Location.Line)
+}
+
+//Group the goroutines that aren't the runtime's own by what they're blocked on, the largest groups first
+func (s *Snapshot) BlockedGroups() []*BlockedGroup {
+	byKey := make(map[string]*BlockedGroup)
+	groups := make([]*BlockedGroup, 0)
+	for _, g := range s.Goroutines {
+		if isSystem(g) {
+			continue
+		}
+		b := Classify(g, s.MainModule)
+		group, ok := byKey[b.key()]
+		if !ok {
+			group = &BlockedGroup{Blocked: b}
+			byKey[b.key()] = group
+			groups = append(groups, group)
+		}
+		group.Count++
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groups[i].Count > groups[j].Count
+	})
+	return groups
+}
+
+//Point out likely deadlocks and leaks in the snapshot, and goroutines that grew since the previous one if it isn't nil
+func Analyze(previous *Snapshot, current *Snapshot) []string {
+	groups := current.BlockedGroups()
+	findings := make([]string, 0)
+	waiters := make(map[string]map[uint64]int)
+	progressing := 0
+	for _, group := range groups {
+		switch group.Operation {
+		case OpChanReceive, OpChanSend, OpSelect, OpMutex, OpWaitGroup, OpCond:
+		default:
+			progressing += group.Count
+		}
+		if group.Address == 0 {
+			continue
+		}
+		if waiters[group.Operation] == nil {
+			waiters[group.Operation] = make(map[uint64]int)
+		}
+		waiters[group.Operation][group.Address] += group.Count
+	}
+	if progressing == 0 && len(groups) > 0 {
+		findings = append(findings, "Every goroutine is blocked on a channel, select, mutex, WaitGroup or Cond and none is running, "+
+			"doing IO, sleeping or in a syscall. The process is likely deadlocked, unless a select waits on a timer.")
+	}
+	for _, address := range sortedAddresses(waiters[OpChanSend]) {
+		if waiters[OpChanReceive][address] == 0 {
+			findings = append(findings, fmt.Sprintf("%d goroutines are blocked sending on channel %#x and none is blocked receiving from it. "+
+				"If nothing selects on it the senders are leaked.", waiters[OpChanSend][address], address))
+		}
+	}
+	for _, address := range sortedAddresses(waiters[OpMutex]) {
+		if waiters[OpMutex][address] > 1 {
+			findings = append(findings, fmt.Sprintf("%d goroutines wait for mutex %#x, look for the goroutine holding it. "+
+				"If it waits on something these goroutines hold, it's a deadlock.", waiters[OpMutex][address], address))
+		}
+	}
+	for _, address := range sortedAddresses(waiters[OpWaitGroup]) {
+		findings = append(findings, fmt.Sprintf("%d goroutines wait for WaitGroup %#x, check that every Add has a Done.", waiters[OpWaitGroup][address], address))
+	}
+	if previous != nil {
+		findings = append(findings, growth(previous, current)...)
+	}
+	return findings
+}
+
+func sortedAddresses(counts map[uint64]int) []uint64 {
+	addresses := make([]uint64, 0, len(counts))
+	for address := range counts {
+		addresses = append(addresses, address)
+	}
+	sort.Slice(addresses, func(i, j int) bool { return addresses[i] < addresses[j] })
+	return addresses
+}
+
+//Groups that grew between the snapshots, by operation and location since addresses of new channels change
+func growth(previous *Snapshot, current *Snapshot) []string {
+	count := func(s *Snapshot) map[string]int {
+		counts := make(map[string]int)
+		for _, g := range s.Goroutines {
+			if !isSystem(g) {
+				b := Classify(g, s.MainModule)
+				counts[b.describe()]++
+			}
+		}
+		return counts
+	}
+	before, after := count(previous), count(current)
+	findings := make([]string, 0)
+	if total := len(current.Goroutines) - len(previous.Goroutines); total >= growthThreshold {
+		findings = append(findings, fmt.Sprintf("The process has %d more goroutines than %s before, %d now.",
+			total, current.Time.Sub(previous.Time).Round(time.Second), len(current.Goroutines)))
+	}
+	described := make([]string, 0, len(after))
+	for description := range after {
+		described = append(described, description)
+	}
+	sort.Slice(described, func(i, j int) bool {
+		return after[described[i]]-before[described[i]] > after[described[j]]-before[described[j]]
+	})
+	for _, description := range described {
+		if grew := after[description] - before[description]; grew >= growthThreshold {
+			findings = append(findings, fmt.Sprintf("Goroutines %s grew from %d to %d, they may be leaking.", description, before[description], after[description]))
+		}
+	}
+	return findings
+}
+
+func (b Blocked) describe() string {
+	if b.Operation == OpRunning {
+		return fmt.Sprintf("running at %s:%d", b.Location.File, b.Location.Line)
+	}
+	return fmt.Sprintf("blocked on %s at %s:%d", b.Operation, b.Location.File, b.Location.Line)
+}
+
+//Print the goroutines grouped by what they're blocked on, then what looks wrong
+func PrintAnalysis(out io.Writer, previous *Snapshot, current *Snapshot) {
+	groups := current.BlockedGroups()
+	totals := make(map[string]int)
+	operations := make([]string, 0)
+	for _, group := range groups {
+		if totals[group.Operation] == 0 {
+			operations = append(operations, group.Operation)
+		}
+		totals[group.Operation] += group.Count
+	}
+	sort.SliceStable(operations, func(i, j int) bool { return totals[operations[i]] > totals[operations[j]] })
+	fmt.Fprintf(out, "%d goroutines, pid %d at %s\n\n", len(current.Goroutines), current.Pid, current.Time.Format("15:04:05"))
+	for _, operation := range operations {
+		fmt.Fprintf(out, "%-13s %d\n", operation, totals[operation])
+	}
+	fmt.Fprintln(out)
+	for _, group := range groups {
+		on := ""
+		if group.Address != 0 {
+			on = fmt.Sprintf(" %#x", group.Address)
+		}
+		fmt.Fprintf(out, "%5d %s%s\n      %s\n        %s:%d\n", group.Count, group.Operation, on, group.Location.Function, group.Location.File, group.Location.Line)
+	}
+	findings := Analyze(previous, current)
+	if len(findings) == 0 {
+		fmt.Fprintln(out, "\nNothing looks stuck or leaking")
+		return
+	}
+	fmt.Fprintln(out, "\nWhere to look:")
+	for _, finding := range findings {
+		fmt.Fprintf(out, "  - %s\n", finding)
+	}
+}
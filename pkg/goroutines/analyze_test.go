@@ -0,0 +1,189 @@
+package goroutines
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ncsnw/skavo/pkg/dlv"
+)
+
+//A goroutine waiting in function on the object at address, called from caller in the main module
+func blocked(function string, address uint64, caller string, line int) Goroutine {
+	arg := dlv.Variable{Name: "c", Kind: uint(reflect.Ptr), Children: []dlv.Variable{{Addr: address}}}
+	return Goroutine{
+		Status: "waiting",
+		Stack: []Frame{
+			{Function: "runtime.gopark", File: "runtime/proc.go", Line: 363},
+			{Function: function, File: "runtime/chan.go", Line: 100, Arguments: []dlv.Variable{arg}},
+			{Function: caller, File: "myapp/worker.go", Line: line},
+		},
+	}
+}
+
+func running(caller string) Goroutine {
+	return Goroutine{Status: "running", Stack: []Frame{{Function: caller, File: "myapp/loop.go", Line: 7}}}
+}
+
+func times(n int, g Goroutine) []Goroutine {
+	gs := make([]Goroutine, n)
+	for i := range gs {
+		gs[i] = g
+	}
+	return gs
+}
+
+func snapshot(at time.Time, gs ...[]Goroutine) *Snapshot {
+	s := &Snapshot{Pid: 1, Time: at, MainModule: "myapp"}
+	for _, g := range gs {
+		s.Goroutines = append(s.Goroutines, g...)
+	}
+	return s
+}
+
+func TestAnalyze(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		previous *Snapshot
+		current  *Snapshot
+		want     []string
+		notWant  []string
+	}{
+		{
+			name: "chan send with no receiver",
+			current: snapshot(start,
+				times(3, blocked("runtime.chansend1", 0x100, "myapp/worker.produce", 10)),
+				[]Goroutine{running("myapp/server.serve")},
+			),
+			want:    []string{"3 goroutines are blocked sending on channel 0x100 and none is blocked receiving"},
+			notWant: []string{"deadlocked"},
+		},
+		{
+			name: "chan send with a receiver",
+			current: snapshot(start,
+				[]Goroutine{blocked("runtime.chansend1", 0x100, "myapp/worker.produce", 10)},
+				[]Goroutine{blocked("runtime.chanrecv1", 0x100, "myapp/worker.consume", 20)},
+				[]Goroutine{running("myapp/server.serve")},
+			),
+			notWant: []string{"blocked sending"},
+		},
+		{
+			name: "mutex with several waiters",
+			current: snapshot(start,
+				times(2, blocked("sync.(*Mutex).lockSlow", 0x200, "myapp/cache.(*Cache).Get", 30)),
+				[]Goroutine{running("myapp/server.serve")},
+			),
+			want: []string{"2 goroutines wait for mutex 0x200"},
+		},
+		{
+			name: "every goroutine blocked",
+			current: snapshot(start,
+				[]Goroutine{blocked("runtime.chanrecv1", 0x100, "myapp/worker.consume", 20)},
+				[]Goroutine{blocked("sync.(*Mutex).lockSlow", 0x200, "myapp/cache.(*Cache).Get", 30)},
+			),
+			want: []string{"likely deadlocked"},
+		},
+		{
+			name: "growth across two samples",
+			previous: snapshot(start,
+				[]Goroutine{blocked("runtime.chanrecv1", 0x100, "myapp/worker.consume", 20)},
+				[]Goroutine{running("myapp/server.serve")},
+			),
+			current: snapshot(start.Add(10*time.Second),
+				times(12, blocked("runtime.chanrecv1", 0x100, "myapp/worker.consume", 20)),
+				[]Goroutine{running("myapp/server.serve")},
+			),
+			want: []string{
+				"The process has 11 more goroutines than 10s before, 13 now.",
+				"Goroutines blocked on chan receive at myapp/worker.go:20 grew from 1 to 12",
+			},
+		},
+		{
+			name: "no growth between samples",
+			previous: snapshot(start,
+				times(12, blocked("runtime.chanrecv1", 0x100, "myapp/worker.consume", 20)),
+				[]Goroutine{running("myapp/server.serve")},
+			),
+			current: snapshot(start.Add(10*time.Second),
+				times(12, blocked("runtime.chanrecv1", 0x100, "myapp/worker.consume", 20)),
+				[]Goroutine{running("myapp/server.serve")},
+			),
+			notWant: []string{"grew", "more goroutines"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			findings := strings.Join(Analyze(test.previous, test.current), "\n")
+			for _, want := range test.want {
+				if !strings.Contains(findings, want) {
+					t.Errorf("expected a finding with %q, got:\n%s", want, findings)
+				}
+			}
+			for _, notWant := range test.notWant {
+				if strings.Contains(findings, notWant) {
+					t.Errorf("expected no finding with %q, got:\n%s", notWant, findings)
+				}
+			}
+		})
+	}
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name      string
+		goroutine Goroutine
+		want      Blocked
+	}{
+		{
+			name:      "chan receive",
+			goroutine: blocked("runtime.chanrecv1", 0x100, "myapp/worker.consume", 20),
+			want:      Blocked{Operation: OpChanReceive, Address: 0x100, Location: Frame{Function: "myapp/worker.consume", File: "myapp/worker.go", Line: 20}},
+		},
+		{
+			name:      "select has no address",
+			goroutine: blocked("runtime.selectgo", 0x300, "myapp/worker.loop", 40),
+			want:      Blocked{Operation: OpSelect, Location: Frame{Function: "myapp/worker.loop", File: "myapp/worker.go", Line: 40}},
+		},
+		{
+			name:      "running",
+			goroutine: running("myapp/server.serve"),
+			want:      Blocked{Operation: OpRunning, Location: Frame{Function: "myapp/server.serve", File: "myapp/loop.go", Line: 7}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := Classify(test.goroutine, "myapp")
+			got.Location.Arguments = nil
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("expected %+v, got %+v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestIsStd(t *testing.T) {
+	tests := []struct {
+		function   string
+		mainModule string
+		want       bool
+	}{
+		{"sync.(*Mutex).Lock", "myapp", true},
+		{"net/http.(*conn).serve", "myapp", true},
+		{"runtime.gopark", "", true},
+		{"main.main", "", false},
+		{"github.com/org/lib.Do", "", false},
+		{"myapp/worker.consume", "myapp", false},
+		{"myapp.Run", "myapp", false},
+		{"myapp/worker.(*Pool).run.func1", "myapp", false},
+		{"myapp/worker.Map[myapp/model.User]", "myapp", false},
+		{"myapp/worker.consume", "", true},
+		{"myappextra/worker.consume", "myapp", true},
+	}
+	for _, test := range tests {
+		if got := isStd(test.function, test.mainModule); got != test.want {
+			t.Errorf("isStd(%q, %q) = %v, expected %v", test.function, test.mainModule, got, test.want)
+		}
+	}
+}
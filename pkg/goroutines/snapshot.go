@@ -0,0 +1,129 @@
+package goroutines
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ncsnw/skavo/pkg/buildinfo"
+	"github.com/ncsnw/skavo/pkg/dlv"
+)
+
+//How many frames of each stack are read
+const stackDepth = 50
+
+//Every goroutine of a process, read while it was stopped
+type Snapshot struct {
+	Pid  int       `json:"pid"`
+	Time time.Time `json:"time"`
+	//The module path of the program, empty if the process has no build info
+	MainModule string      `json:"mainModule,omitempty"`
+	Goroutines []Goroutine `json:"goroutines"`
+}
+
+type Goroutine struct {
+	ID     int    `json:"id"`
+	Status string `json:"status"`
+	//Empty unless the goroutine is waiting
+	WaitReason string            `json:"waitReason,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	//The go statement that started the goroutine
+	CreatedBy *Frame  `json:"createdBy,omitempty"`
+	Stack     []Frame `json:"stack"`
+	//Why the goroutine or its stack couldn't be read
+	Unreadable string `json:"unreadable,omitempty"`
+}
+
+type Frame struct {
+	Function  string         `json:"function"`
+	File      string         `json:"file"`
+	Line      int            `json:"line"`
+	Arguments []dlv.Variable `json:"arguments,omitempty"`
+	Locals    []dlv.Variable `json:"locals,omitempty"`
+}
+
+//Loads just enough of the arguments to tell which channel or mutex a goroutine waits on
+var AddressLoadConfig = dlv.LoadConfig{}
+
+//Read every goroutine and its stack from the stopped process, with the arguments and locals of each frame loaded with
+//cfg if it isn't nil
+func Collect(client *dlv.Client, pid int, cfg *dlv.LoadConfig) (*Snapshot, error) {
+	all, err := client.AllGoroutines()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list goroutines: %w", err)
+	}
+	//without the names the wait reasons are shown by number
+	reasons, _ := client.WaitReasons()
+	snapshot := &Snapshot{Pid: pid, Time: time.Now(), Goroutines: make([]Goroutine, 0, len(all))}
+	//the main module tells the program's packages from the standard library
+	if modinfo, err := client.Eval(dlv.EvalScope{GoroutineID: -1}, "runtime.modinfo", &dlv.LoadConfig{MaxStringLen: 4096}); err == nil {
+		snapshot.MainModule = buildinfo.MainModule(modinfo.Value)
+	}
+	for _, g := range all {
+		goroutine := Goroutine{
+			ID:         g.ID,
+			Status:     g.StatusName(),
+			WaitReason: g.WaitReasonName(reasons),
+			Labels:     g.Labels,
+			Unreadable: g.Unreadable,
+		}
+		if g.GoStatementLoc.PC != 0 {
+			created := frame(g.GoStatementLoc)
+			goroutine.CreatedBy = &created
+		}
+		stack, err := client.Stacktrace(g.ID, stackDepth, cfg)
+		if err != nil {
+			goroutine.Unreadable = err.Error()
+		}
+		goroutine.Stack = Stack(stack)
+		snapshot.Goroutines = append(snapshot.Goroutines, goroutine)
+	}
+	return snapshot, nil
+}
+
+//Convert the frames delve returns, keeping their arguments and locals
+func Stack(stack []dlv.Stackframe) []Frame {
+	frames := make([]Frame, 0, len(stack))
+	for _, sf := range stack {
+		f := frame(sf.Location)
+		f.Arguments = sf.Arguments
+		f.Locals = sf.Locals
+		frames = append(frames, f)
+	}
+	return frames
+}
+
+func frame(loc dlv.Location) Frame {
+	f := Frame{File: loc.File, Line: loc.Line}
+	if loc.Function != nil {
+		f.Function = loc.Function.Name
+	}
+	return f
+}
+
+func (s *Snapshot) WriteJSON(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	encoder := json.NewEncoder(f)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(s); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func ReadJSON(path string) (*Snapshot, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &Snapshot{}
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return s, nil
+}
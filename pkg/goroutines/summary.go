@@ -0,0 +1,82 @@
+package goroutines
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+//Goroutines with identical stacks in the same state
+type Group struct {
+	Status     string
+	WaitReason string
+	Stack      []Frame
+	CreatedBy  *Frame
+	IDs        []int
+}
+
+//Collapse goroutines with the same state, stack and creation site, the largest groups first
+func (s *Snapshot) Groups() []*Group {
+	byKey := make(map[string]*Group)
+	groups := make([]*Group, 0)
+	for _, g := range s.Goroutines {
+		key := groupKey(g)
+		group, ok := byKey[key]
+		if !ok {
+			group = &Group{Status: g.Status, WaitReason: g.WaitReason, Stack: g.Stack, CreatedBy: g.CreatedBy}
+			byKey[key] = group
+			groups = append(groups, group)
+		}
+		group.IDs = append(group.IDs, g.ID)
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		return len(groups[i].IDs) > len(groups[j].IDs)
+	})
+	return groups
+}
+
+func groupKey(g Goroutine) string {
+	key := &strings.Builder{}
+	fmt.Fprintf(key, "%s|%s|", g.Status, g.WaitReason)
+	for _, f := range g.Stack {
+		fmt.Fprintf(key, "%s:%d|", f.Function, f.Line)
+	}
+	if g.CreatedBy != nil {
+		fmt.Fprintf(key, "%s:%d", g.CreatedBy.File, g.CreatedBy.Line)
+	}
+	return key.String()
+}
+
+//Print the groups with their stacks, like a goroutine dump with duplicates collapsed
+func (s *Snapshot) PrintSummary(out io.Writer) {
+	groups := s.Groups()
+	fmt.Fprintf(out, "%d goroutines in %d groups, pid %d at %s\n", len(s.Goroutines), len(groups), s.Pid, s.Time.Format("15:04:05"))
+	for _, group := range groups {
+		state := group.Status
+		if group.WaitReason != "" {
+			state += ", " + group.WaitReason
+		}
+		fmt.Fprintf(out, "\n%d goroutines [%s]: %s\n", len(group.IDs), state, formatIDs(group.IDs))
+		for _, f := range group.Stack {
+			fmt.Fprintf(out, "    %s\n        %s:%d\n", f.Function, f.File, f.Line)
+		}
+		if group.CreatedBy != nil {
+			fmt.Fprintf(out, "    created by %s at %s:%d\n", group.CreatedBy.Function, group.CreatedBy.File, group.CreatedBy.Line)
+		}
+	}
+}
+
+//The first few ids, a group can have thousands
+func formatIDs(ids []int) string {
+	const shown = 10
+	formatted := make([]string, 0, shown+1)
+	for i, id := range ids {
+		if i == shown {
+			formatted = append(formatted, fmt.Sprintf("and %d more", len(ids)-shown))
+			break
+		}
+		formatted = append(formatted, fmt.Sprint(id))
+	}
+	return strings.Join(formatted, ", ")
+}
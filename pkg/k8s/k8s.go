@@ -13,6 +13,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -39,17 +40,31 @@ type Client struct {
 	CertsClient     *certsv1client.CertificatesV1Client
 	BatchClient     *batchv1client.BatchV1Client
 	RbacClient      *rbacv1client.RbacV1Client
-	config          *rest.Config
+	//The namespace set on the kubeconfig context, empty if the context doesn't set one
+	Namespace string
+	config    *rest.Config
 }
 
 func NewK8sClient(context string, kubeconfig *string) *Client {
-	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
 		&clientcmd.ClientConfigLoadingRules{ExplicitPath: *kubeconfig},
 		&clientcmd.ConfigOverrides{CurrentContext: context},
-	).ClientConfig()
+	)
+	config, err := clientConfig.ClientConfig()
 	if err != nil {
 		panic(fmt.Errorf("failed to build config %w", err))
 	}
+	rawConfig, err := clientConfig.RawConfig()
+	if err != nil {
+		panic(fmt.Errorf("failed to load kubeconfig %w", err))
+	}
+	if context == "" {
+		context = rawConfig.CurrentContext
+	}
+	namespace := ""
+	if kubeContext, ok := rawConfig.Contexts[context]; ok {
+		namespace = kubeContext.Namespace
+	}
 	return &Client{
 		corev1client.NewForConfigOrDie(config),
 		appsv1client.NewForConfigOrDie(config),
@@ -57,63 +72,75 @@ func NewK8sClient(context string, kubeconfig *string) *Client {
 		certsv1client.NewForConfigOrDie(config),
 		batchv1client.NewForConfigOrDie(config),
 		rbacv1client.NewForConfigOrDie(config),
+		namespace,
 		config,
 	}
 }
 
-func (kc *Client) ListPods(namespace string) *v1.PodList {
-	pods, err := kc.CoreClient.Pods(namespace).List(context.TODO(), metav1.ListOptions{})
+func (kc *Client) ListPods(namespace string, options metav1.ListOptions) *v1.PodList {
+	pods, err := kc.CoreClient.Pods(namespace).List(context.TODO(), options)
 	util.MaybePanic(err)
 	return pods
 }
 
-type ContainerProcess struct {
-	Pid     int
-	Command []string
+//Filter out the pods that can't be exec'd into. A pod in CrashLoopBackOff is in the Running phase, so the container
+//has to be running too, or every container if container is empty.
+func RunningPods(pods []v1.Pod, container string) []v1.Pod {
+	running := make([]v1.Pod, 0, len(pods))
+	for _, pod := range pods {
+		if pod.Status.Phase == v1.PodRunning && pod.DeletionTimestamp == nil && ContainersRunning(&pod, container) {
+			running = append(running, pod)
+		}
+	}
+	return running
 }
 
-func (kc *Client) ListProcesses(pod *v1.Pod, containerName string) []ContainerProcess {
-	out := new(bytes.Buffer)
-	util.MaybePanic(kc.Exec(
-		pod.Name,
-		pod.Namespace,
-		containerName,
-		[]string{"sh", "-c",
-			//ps isn't very consistent, so
-			"for p in $(find /proc -maxdepth 1|grep -E \"/[0-9]+$\"); do " +
-				"pid=$(echo \"$p\"|cut -d/ -f3); " +
-				"echo removethisline; " +
-				"if [ -f \"$p\"/cmdline ]; then " +
-				"cmd=$(xargs -0 -n1 < \"$p\"/cmdline |sed '/^$/d'|sed -E 's/^(.*)$/\"\\1\"/'|tr '\\n' ' '); " +
-				"fi; " +
-				"if [ ! -z \"$cmd\" ]; then " +
-				"echo \"$pid|$cmd\"; " +
-				"fi; " +
-				"done | grep -v removethisline",
-		},
-		ExecOptions{
-			Out:    out,
-			ErrOut: os.Stderr,
-		},
-	))
-	//for p in $(find /proc -maxdepth 1|grep -E "/[0-9]+$"); do pid=$(echo "$p"|cut -d/ -f3);
-	output := out.String()
-	lines := strings.Split(output, "\n")
-	processes := make([]ContainerProcess, 0)
-	for _, line := range lines {
-		line = strings.Trim(line, " \t")
-		if line == "" {
+//Whether the container is running in the pod, or every container if container is empty
+func ContainersRunning(pod *v1.Pod, container string) bool {
+	if len(pod.Status.ContainerStatuses) == 0 {
+		return false
+	}
+	found := false
+	for _, status := range pod.Status.ContainerStatuses {
+		if container != "" && status.Name != container {
 			continue
 		}
-		parts := strings.Split(line, "|")
-		pid, err := strconv.Atoi(strings.Trim(parts[0], " \t\n"))
-		if err != nil {
-			panic(fmt.Errorf("unexpected output \n\n%s\n\n %+v", output, err))
+		if status.State.Running == nil {
+			return false
 		}
-		cmd := strings.Split(strings.Trim(parts[1], "\""), "\" \"")
-		processes = append(processes, ContainerProcess{Pid: pid, Command: cmd})
+		found = true
+	}
+	return found
+}
+
+func (kc *Client) ListNamespaces() []string {
+	namespaces, err := kc.CoreClient.Namespaces().List(context.TODO(), metav1.ListOptions{})
+	util.MaybePanic(err)
+	names := make([]string, len(namespaces.Items))
+	for i, ns := range namespaces.Items {
+		names[i] = ns.Name
 	}
-	return processes
+	return names
+}
+
+//Find the pods with the given name in all namespaces
+func (kc *Client) FindPods(name string) []v1.Pod {
+	return kc.ListPods("", metav1.ListOptions{FieldSelector: "metadata.name=" + name}).Items
+}
+
+type ContainerProcess struct {
+	Pid  int
+	PPid int
+	Uid  int
+	//The user name from the container's /etc/passwd, empty if the uid isn't listed
+	User      string
+	StartTime time.Time
+	//Resident memory in bytes
+	Rss   int64
+	State string
+	//The path of the executable, empty if it can't be read
+	Exe     string
+	Command []string
 }
 
 type ExecOptions struct {
@@ -193,7 +220,47 @@ func (kc *Client) CopyToPod(namespace string, podName string, containerName stri
 	))
 }
 
-func (kc *Client) ForwardPort(namespace string, podName string, localPort string, podPort string) <-chan struct{} {
+//Archives the path with tar, following it if it's a link to a file, like /proc/<pid>/exe, so the file is copied
+const archivePath = `cd "$(dirname "$1")" || exit 1
+if [ -L "$1" ] && [ ! -d "$1" ]; then
+	exec tar -chf - "$(basename "$1")"
+fi
+exec tar -cf - "$(basename "$1")"
+`
+
+//Copy a file or directory from the pod to destPath on this machine, streamed with tar like kubectl cp
+func (kc *Client) CopyFromPod(namespace string, podName string, containerName string, srcPath string, destPath string) {
+	srcPath = path.Clean(srcPath)
+	reader, writer := io.Pipe()
+	errOut := &bytes.Buffer{}
+	go func() {
+		err := kc.Exec(
+			podName,
+			namespace,
+			containerName,
+			[]string{"sh", "-c", archivePath, "sh", srcPath},
+			ExecOptions{nil, writer, errOut},
+		)
+		if err != nil {
+			err = fmt.Errorf("%s %+v", strings.TrimSpace(errOut.String()), err)
+		}
+		writer.CloseWithError(err)
+	}()
+	progress := newCopyProgress(reader, os.Stdout)
+	err := untar(progress, path.Base(srcPath), destPath)
+	progress.done()
+	if err != nil {
+		//stops the exec too
+		reader.CloseWithError(err)
+		panic(fmt.Errorf("failed to copy %s from the pod: %+v", srcPath, err))
+	}
+	//read the end of the archive so the exec can finish
+	_, _ = io.Copy(ioutil.Discard, reader)
+}
+
+//Forward the local port on address to the pod port, returns once the port is ready with the local port, which is picked
+//when localPort is 0. Close the returned channel to stop forwarding.
+func (kc *Client) ForwardPort(namespace string, podName string, address string, localPort string, podPort string) (chan struct{}, string) {
 	url := kc.CoreClient.RESTClient().Post().
 		Resource("pods").
 		Namespace(namespace).
@@ -207,18 +274,29 @@ func (kc *Client) ForwardPort(namespace string, podName string, localPort string
 	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: transport}, "POST", url)
 	stopChan := make(chan struct{})
 	readyChan := make(chan struct{})
-	fw, err := portforward.NewOnAddresses(dialer, []string{"127.0.0.1"}, []string{localPort + ":" + podPort}, stopChan, readyChan, os.Stdout, os.Stderr)
+	fw, err := portforward.NewOnAddresses(dialer, []string{address}, []string{localPort + ":" + podPort}, stopChan, readyChan, os.Stdout, os.Stderr)
 	if err != nil {
 		panic(fmt.Errorf("failed to create port forward: %+v", err))
 	}
-	err = fw.ForwardPorts()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := fw.ForwardPorts(); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to forward ports: %+v\n", err)
+		}
+	}()
+	fmt.Println("Waiting for port forward to be ready...")
+	select {
+	case <-readyChan:
+	case <-done:
+		panic(fmt.Errorf("failed to forward ports"))
+	}
+	ports, err := fw.GetPorts()
 	if err != nil {
-		panic(fmt.Errorf("failed to forward ports: %+v", err))
+		panic(fmt.Errorf("failed to get forwarded ports: %+v", err))
 	}
-	fmt.Println("Waiting for port forward to be ready...")
-	<-readyChan
 	fmt.Println("Ports forwarded!...")
-	return stopChan
+	return stopChan, strconv.Itoa(int(ports[0].Local))
 }
 
 func makeTar(srcPath, destPath string, writer io.Writer) {
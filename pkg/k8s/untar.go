@@ -0,0 +1,206 @@
+package k8s
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+//Extract a tar archive of srcBase to destPath. Entries outside of srcBase or destPath, or that would be written through
+//a link, are refused, and links that could lead outside of destPath are skipped along with refusing the entries under
+//them. A container shouldn't be able to write anywhere else on this machine.
+func untar(r io.Reader, srcBase string, destPath string) error {
+	destPath = filepath.Clean(destPath)
+	tr := tar.NewReader(r)
+	skipped := make(map[string]bool)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		target, err := extractPath(hdr.Name, srcBase, destPath)
+		if err != nil {
+			return err
+		}
+		//the archive means the skipped link's target, not a directory of the same name
+		for dir := filepath.Dir(target); isWithin(dir, destPath); dir = filepath.Dir(dir) {
+			if skipped[dir] {
+				return fmt.Errorf("%s is under the skipped link %s", hdr.Name, dir)
+			}
+			if dir == destPath {
+				break
+			}
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(target, 0755)
+		case tar.TypeReg:
+			err = writeFile(target, tr, os.FileMode(hdr.Mode).Perm())
+		case tar.TypeSymlink:
+			if err := checkLink(target, hdr.Linkname, destPath); err != nil {
+				fmt.Fprintf(os.Stderr, "Skipping %s: %+v\n", hdr.Name, err)
+				skipped[target] = true
+				continue
+			}
+			err = makeLink(target, func() error { return os.Symlink(hdr.Linkname, target) })
+		case tar.TypeLink:
+			//hard links name another entry of the archive
+			linked, linkErr := extractPath(hdr.Linkname, srcBase, destPath)
+			if linkErr != nil {
+				return linkErr
+			}
+			err = makeLink(target, func() error { return os.Link(linked, target) })
+		default:
+			fmt.Fprintf(os.Stderr, "Skipping %s, it isn't a file, directory or link\n", hdr.Name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+//Where an archive entry goes under destPath
+func extractPath(name string, srcBase string, destPath string) (string, error) {
+	name = strings.TrimPrefix(name, "./")
+	clean := path.Clean(name)
+	if path.IsAbs(name) || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("%s is outside of the copied path", name)
+	}
+	var target string
+	switch {
+	case clean == srcBase:
+		target = destPath
+	case strings.HasPrefix(clean, srcBase+"/"):
+		target = filepath.Join(destPath, filepath.FromSlash(strings.TrimPrefix(clean, srcBase+"/")))
+	default:
+		return "", fmt.Errorf("%s is outside of %s", name, srcBase)
+	}
+	if !isWithin(target, destPath) {
+		return "", fmt.Errorf("%s is outside of %s", name, destPath)
+	}
+	//don't follow links already there, they may point anywhere
+	if err := throughLink(filepath.Dir(target), destPath); err != nil {
+		return "", fmt.Errorf("%s %w", name, err)
+	}
+	return target, nil
+}
+
+//Links are resolved on this machine, so they must stay in destPath without going up or through other links. Chains
+//of links that each only go down stay in destPath.
+func checkLink(target string, linkname string, destPath string) error {
+	if filepath.IsAbs(linkname) {
+		return fmt.Errorf("it links to the absolute path %s", linkname)
+	}
+	for _, part := range strings.Split(filepath.ToSlash(linkname), "/") {
+		if part == ".." {
+			return fmt.Errorf("it links to %s, which goes up a directory", linkname)
+		}
+	}
+	resolved := filepath.Join(filepath.Dir(target), linkname)
+	if !isWithin(resolved, destPath) {
+		return fmt.Errorf("it links to %s outside of %s", linkname, destPath)
+	}
+	if err := throughLink(resolved, destPath); err != nil {
+		return fmt.Errorf("it links to %s, which %w", linkname, err)
+	}
+	if stat, err := os.Lstat(resolved); err == nil && stat.Mode()&os.ModeSymlink != 0 {
+		return fmt.Errorf("it links to %s, which is another link", linkname)
+	}
+	return nil
+}
+
+//Check that none of the existing directories from destPath down to p are links, which would take writes elsewhere
+func throughLink(p string, destPath string) error {
+	rel, err := filepath.Rel(destPath, p)
+	if err != nil || rel == "." {
+		return err
+	}
+	dir := destPath
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		dir = filepath.Join(dir, part)
+		stat, err := os.Lstat(dir)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if stat.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("goes through the link %s", dir)
+		}
+	}
+	return nil
+}
+
+func isWithin(p string, dir string) bool {
+	p = filepath.Clean(p)
+	return p == dir || strings.HasPrefix(p, dir+string(filepath.Separator))
+}
+
+func writeFile(target string, r io.Reader, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	//replace rather than write through whatever is there
+	if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, mode)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := io.Copy(f, r); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func makeLink(target string, link func() error) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return link()
+}
+
+//Counts what's read and prints it every so often
+type copyProgress struct {
+	r       io.Reader
+	out     io.Writer
+	total   int64
+	printed time.Time
+}
+
+func newCopyProgress(r io.Reader, out io.Writer) *copyProgress {
+	return &copyProgress{r: r, out: out, printed: time.Now()}
+}
+
+func (p *copyProgress) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.total += int64(n)
+	if time.Since(p.printed) > 500*time.Millisecond {
+		p.print()
+	}
+	return n, err
+}
+
+func (p *copyProgress) print() {
+	fmt.Fprintf(p.out, "\rCopied %.1fMi", float64(p.total)/(1024*1024))
+	p.printed = time.Now()
+}
+
+func (p *copyProgress) done() {
+	p.print()
+	fmt.Fprintln(p.out)
+}
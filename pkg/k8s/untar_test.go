@@ -0,0 +1,140 @@
+package k8s
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type entry struct {
+	name     string
+	typeflag byte
+	linkname string
+	body     string
+}
+
+func archive(t *testing.T, entries ...entry) *bytes.Buffer {
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Typeflag: e.typeflag, Linkname: e.linkname, Mode: 0644, Size: int64(len(e.body))}
+		if e.typeflag == tar.TypeDir {
+			hdr.Mode = 0755
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf
+}
+
+//Extract to dest in a fresh directory and fail if anything was written next to dest
+func extract(t *testing.T, entries ...entry) (string, error) {
+	parent := t.TempDir()
+	dest := filepath.Join(parent, "dest")
+	err := untar(archive(t, entries...), "base", dest)
+	files, readErr := os.ReadDir(parent)
+	if readErr != nil {
+		t.Fatal(readErr)
+	}
+	for _, f := range files {
+		if f.Name() != "dest" {
+			t.Errorf("%s was written outside of the destination", f.Name())
+		}
+	}
+	return dest, err
+}
+
+func TestUntar(t *testing.T) {
+	dest, err := extract(t,
+		entry{name: "base/", typeflag: tar.TypeDir},
+		entry{name: "base/dir/", typeflag: tar.TypeDir},
+		entry{name: "base/dir/file.txt", typeflag: tar.TypeReg, body: "hello"},
+		entry{name: "base/link", typeflag: tar.TypeSymlink, linkname: "dir/file.txt"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dest, "link"))
+	if err != nil || string(data) != "hello" {
+		t.Errorf("expected the link to read hello, got %q %v", data, err)
+	}
+}
+
+func TestUntarSymlinkChain(t *testing.T) {
+	dest, err := extract(t,
+		entry{name: "base/", typeflag: tar.TypeDir},
+		entry{name: "base/l2", typeflag: tar.TypeSymlink, linkname: "."},
+		entry{name: "base/l1", typeflag: tar.TypeSymlink, linkname: "l2/.."},
+		entry{name: "base/l1/escaped/evil.txt", typeflag: tar.TypeReg, body: "evil"},
+	)
+	if err == nil {
+		t.Error("expected writing under the link that goes up to fail")
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(dest), "escaped", "evil.txt")); !os.IsNotExist(err) {
+		t.Error("evil.txt was written outside of the destination")
+	}
+	if _, err := os.Stat(filepath.Join(dest, "l1", "escaped", "evil.txt")); !os.IsNotExist(err) {
+		t.Error("evil.txt was written under the skipped link")
+	}
+}
+
+func TestUntarThroughLink(t *testing.T) {
+	_, err := extract(t,
+		entry{name: "base/", typeflag: tar.TypeDir},
+		entry{name: "base/l2", typeflag: tar.TypeSymlink, linkname: "."},
+		entry{name: "base/l2/file.txt", typeflag: tar.TypeReg, body: "through"},
+	)
+	if err == nil {
+		t.Error("expected writing through a link to fail")
+	}
+}
+
+func TestUntarLinkToLink(t *testing.T) {
+	dest, err := extract(t,
+		entry{name: "base/", typeflag: tar.TypeDir},
+		entry{name: "base/file.txt", typeflag: tar.TypeReg, body: "hello"},
+		entry{name: "base/l1", typeflag: tar.TypeSymlink, linkname: "file.txt"},
+		entry{name: "base/l2", typeflag: tar.TypeSymlink, linkname: "l1"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Lstat(filepath.Join(dest, "l2")); !os.IsNotExist(err) {
+		t.Error("expected the link to another link to be skipped")
+	}
+}
+
+func TestUntarParentEntry(t *testing.T) {
+	_, err := extract(t,
+		entry{name: "base/", typeflag: tar.TypeDir},
+		entry{name: "base/../evil.txt", typeflag: tar.TypeReg, body: "evil"},
+	)
+	if err == nil {
+		t.Error("expected an entry outside of the destination to fail")
+	}
+}
+
+func TestUntarAbsoluteLink(t *testing.T) {
+	dest, err := extract(t,
+		entry{name: "base/", typeflag: tar.TypeDir},
+		entry{name: "base/passwd", typeflag: tar.TypeSymlink, linkname: "/etc/passwd"},
+		entry{name: "base/up", typeflag: tar.TypeSymlink, linkname: "../.."},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"passwd", "up"} {
+		if _, err := os.Lstat(filepath.Join(dest, name)); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be skipped", name)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+//List the pods of a workload given like kubectl does, kind/name, e.g. deploy/api or statefulset/db
+func (kc *Client) WorkloadPods(namespace string, workload string) ([]v1.Pod, error) {
+	options, err := kc.WorkloadSelector(namespace, workload)
+	if err != nil {
+		return nil, err
+	}
+	return kc.ListPods(namespace, options).Items, nil
+}
+
+//The list options that select the pods of the workload
+func (kc *Client) WorkloadSelector(namespace string, workload string) (metav1.ListOptions, error) {
+	parts := strings.SplitN(workload, "/", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return metav1.ListOptions{}, fmt.Errorf("invalid workload %s, expected kind/name like deploy/api", workload)
+	}
+	kind, name := strings.ToLower(parts[0]), parts[1]
+	var selector *metav1.LabelSelector
+	var err error
+	switch kind {
+	case "po", "pod", "pods":
+		return metav1.ListOptions{FieldSelector: "metadata.name=" + name}, nil
+	case "deploy", "deployment", "deployments":
+		d, getErr := kc.AppsClient.Deployments(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+		if err = getErr; err == nil {
+			selector = d.Spec.Selector
+		}
+	case "sts", "statefulset", "statefulsets":
+		s, getErr := kc.AppsClient.StatefulSets(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+		if err = getErr; err == nil {
+			selector = s.Spec.Selector
+		}
+	case "ds", "daemonset", "daemonsets":
+		d, getErr := kc.AppsClient.DaemonSets(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+		if err = getErr; err == nil {
+			selector = d.Spec.Selector
+		}
+	case "rs", "replicaset", "replicasets":
+		r, getErr := kc.AppsClient.ReplicaSets(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+		if err = getErr; err == nil {
+			selector = r.Spec.Selector
+		}
+	case "job", "jobs":
+		j, getErr := kc.BatchClient.Jobs(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+		if err = getErr; err == nil {
+			selector = j.Spec.Selector
+		}
+	default:
+		return metav1.ListOptions{}, fmt.Errorf("unsupported workload kind %s, expected deploy, sts, ds, rs, job or pod", parts[0])
+	}
+	if err != nil {
+		return metav1.ListOptions{}, err
+	}
+	labels, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return metav1.ListOptions{}, fmt.Errorf("invalid selector on %s: %w", workload, err)
+	}
+	return metav1.ListOptions{LabelSelector: labels.String()}, nil
+}
+
+//Watch the pods the options select with an informer and send each one once the container is running, existing pods
+//included, until stop is closed. A pod is sent again each time the container is restarted and running again, every
+//container is watched if container is empty.
+func (kc *Client) WatchRunningPods(namespace string, options metav1.ListOptions, container string, stop chan struct{}) chan *v1.Pod {
+	pods := kc.CoreClient.Pods(namespace)
+	lw := &cache.ListWatch{
+		ListFunc: func(o metav1.ListOptions) (runtime.Object, error) {
+			o.LabelSelector, o.FieldSelector = options.LabelSelector, options.FieldSelector
+			return pods.List(context.TODO(), o)
+		},
+		WatchFunc: func(o metav1.ListOptions) (watch.Interface, error) {
+			o.LabelSelector, o.FieldSelector = options.LabelSelector, options.FieldSelector
+			return pods.Watch(context.TODO(), o)
+		},
+	}
+	running := make(chan *v1.Pod, 16)
+	//only touched by the informer's handlers, which run one at a time
+	sent := make(map[string]bool)
+	check := func(obj interface{}) {
+		pod, ok := obj.(*v1.Pod)
+		if !ok || len(RunningPods([]v1.Pod{*pod}, container)) == 0 {
+			return
+		}
+		key := RunningKey(pod, container)
+		if key == "" || sent[key] {
+			return
+		}
+		sent[key] = true
+		select {
+		case running <- pod:
+		case <-stop:
+		}
+	}
+	_, informer := cache.NewInformer(lw, &v1.Pod{}, 0, cache.ResourceEventHandlerFuncs{
+		AddFunc:    check,
+		UpdateFunc: func(_, obj interface{}) { check(obj) },
+	})
+	go informer.Run(stop)
+	return running
+}
+
+//Identifies the pod with the id of the container, a restarted container has a new id so the key changes, while a
+//restarted sidecar doesn't. Every container counts if container is empty. Empty until the containers are running.
+func RunningKey(pod *v1.Pod, container string) string {
+	if !ContainersRunning(pod, container) {
+		return ""
+	}
+	key := string(pod.UID)
+	for _, status := range pod.Status.ContainerStatuses {
+		if container != "" && status.Name != container {
+			continue
+		}
+		if status.ContainerID == "" {
+			return ""
+		}
+		key += " " + status.ContainerID
+	}
+	return key
+}
@@ -1,26 +1,105 @@
 package prompt
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/AlecAivazis/survey/v2"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/duration"
 
+	"github.com/ncsnw/skavo/pkg/discover"
 	"github.com/ncsnw/skavo/pkg/k8s"
+	"github.com/ncsnw/skavo/pkg/util"
 )
 
+//The ALL option returned by SelectNamespace
+const AllNamespaces = "ALL"
+
+func SelectNamespace(namespaces []string) string {
+	options := append([]string{AllNamespaces}, namespaces...)
+	return options[GetSelection("Select a Namespace:", options)]
+}
+
 func SelectPod(pods []v1.Pod) *v1.Pod {
-	podNames := make([]string, len(pods))
-	for i, pod := range pods {
-		podNames[i] = pod.Name
-	}
 	if len(pods) < 1 {
 		panic("no pods found")
 	}
-	return &pods[GetSelection("Select a Pod:", podNames)]
+	multipleNamespaces := false
+	for _, pod := range pods {
+		multipleNamespaces = multipleNamespaces || pod.Namespace != pods[0].Namespace
+	}
+	rows := make([][]string, len(pods))
+	for i, pod := range pods {
+		name := pod.Name
+		if multipleNamespaces {
+			name = pod.Namespace + "/" + pod.Name
+		}
+		ready, restarts := 0, int32(0)
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.Ready {
+				ready++
+			}
+			restarts += status.RestartCount
+		}
+		rows[i] = []string{
+			name,
+			podStatus(pod),
+			fmt.Sprintf("%d/%d", ready, len(pod.Spec.Containers)),
+			fmt.Sprintf("%d restarts", restarts),
+			duration.HumanDuration(time.Since(pod.CreationTimestamp.Time)),
+			pod.Spec.NodeName,
+			podOwner(pod),
+		}
+	}
+	return &pods[GetSelection("Select a Pod:", columns(rows))]
+}
+
+//The status kubectl would show for the pod
+func podStatus(pod v1.Pod) string {
+	if pod.DeletionTimestamp != nil {
+		return "Terminating"
+	}
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.State.Waiting != nil && status.State.Waiting.Reason != "" {
+			return status.State.Waiting.Reason
+		}
+		if status.State.Terminated != nil && status.State.Terminated.Reason != "" {
+			return status.State.Terminated.Reason
+		}
+	}
+	return string(pod.Status.Phase)
+}
+
+//The workload that owns the pod, ReplicaSets created by a Deployment are shown as the Deployment
+func podOwner(pod v1.Pod) string {
+	if len(pod.OwnerReferences) < 1 {
+		return ""
+	}
+	owner := pod.OwnerReferences[0]
+	if hash, ok := pod.Labels["pod-template-hash"]; ok && owner.Kind == "ReplicaSet" && strings.HasSuffix(owner.Name, "-"+hash) {
+		return "Deployment/" + strings.TrimSuffix(owner.Name, "-"+hash)
+	}
+	return owner.Kind + "/" + owner.Name
+}
+
+//Align the rows into columns
+func columns(rows [][]string) []string {
+	buf := new(bytes.Buffer)
+	w := tabwriter.NewWriter(buf, 0, 0, 2, ' ', 0)
+	for _, row := range rows {
+		_, _ = fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	util.MaybePanic(w.Flush())
+	return strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
 }
 
 func SelectContainer(containers []v1.Container) v1.Container {
@@ -36,26 +115,119 @@ func SelectContainer(containers []v1.Container) v1.Container {
 	return containers[GetSelection("Select a Container:", containerNames)]
 }
 
+func SelectContainerName(names []string) string {
+	if len(names) < 1 {
+		panic("no containers found")
+	}
+	if len(names) < 2 {
+		fmt.Println("One container found")
+		return names[0]
+	}
+	return names[GetSelection("Select a Container:", names)]
+}
+
 func SelectProcess(processList []k8s.ContainerProcess, processFilter string) k8s.ContainerProcess {
 	if processFilter != "" {
 		filtered := []k8s.ContainerProcess{}
 		for _, process := range processList {
-			if match, _ := regexp.MatchString(processFilter, strings.Join(process.Command, " ")); match {
-				filtered = append(filtered, process)
+			for _, field := range []string{strings.Join(process.Command, " "), process.User, process.Exe} {
+				if match, _ := regexp.MatchString(processFilter, field); match {
+					filtered = append(filtered, process)
+					break
+				}
 			}
 		}
 		processList = filtered
 	}
+	if len(processList) < 1 {
+		panic("no processes found")
+	}
 	if len(processList) < 2 {
 		fmt.Println("One process found")
 		return processList[0]
 	}
-	commands := make([]string, len(processList))
-	for i, command := range processList {
-		commands[i] = strings.Join(command.Command, " ")
+	processList, depths := processTree(processList)
+	rows := make([][]string, len(processList))
+	for i, process := range processList {
+		user := process.User
+		if user == "" {
+			user = strconv.Itoa(process.Uid)
+		}
+		rows[i] = []string{
+			strings.Repeat("  ", depths[i]) + strconv.Itoa(process.Pid) + " " + strings.Join(process.Command, " "),
+			user,
+			process.State,
+			fmt.Sprintf("%dMi", process.Rss/(1024*1024)),
+			duration.HumanDuration(time.Since(process.StartTime)),
+			process.Exe,
+		}
 	}
 
-	return processList[GetSelection("Select a Process:", commands)]
+	return processList[GetSelection("Select a Process:", columns(rows))]
+}
+
+func matchRows(matches []discover.Match) [][]string {
+	rows := make([][]string, len(matches))
+	for i, match := range matches {
+		goVersion := match.GoVersion
+		if goVersion == "" {
+			goVersion = "-"
+		}
+		rows[i] = []string{
+			match.Pod.Namespace,
+			match.Pod.Name,
+			match.Container,
+			strconv.Itoa(match.Process.Pid),
+			strings.Join(match.Process.Command, " "),
+			goVersion,
+		}
+	}
+	return rows
+}
+
+//Print the processes skavo ps found as a table
+func PrintMatches(out io.Writer, matches []discover.Match) {
+	header := []string{"NAMESPACE", "POD", "CONTAINER", "PID", "COMMAND", "GO"}
+	for _, row := range columns(append([][]string{header}, matchRows(matches)...)) {
+		_, _ = fmt.Fprintln(out, row)
+	}
+}
+
+func SelectMatch(matches []discover.Match) discover.Match {
+	if len(matches) < 1 {
+		panic("no processes found")
+	}
+	return matches[GetSelection("Select a Process to debug:", columns(matchRows(matches)))]
+}
+
+//Order the processes so children follow their parent, and return how deep each process is in the tree
+func processTree(processList []k8s.ContainerProcess) ([]k8s.ContainerProcess, []int) {
+	sort.Slice(processList, func(i, j int) bool {
+		return processList[i].Pid < processList[j].Pid
+	})
+	pids := make(map[int]bool)
+	children := make(map[int][]k8s.ContainerProcess)
+	for _, process := range processList {
+		pids[process.Pid] = true
+		children[process.PPid] = append(children[process.PPid], process)
+	}
+	ordered := make([]k8s.ContainerProcess, 0, len(processList))
+	depths := make([]int, 0, len(processList))
+	var walk func(process k8s.ContainerProcess, depth int)
+	walk = func(process k8s.ContainerProcess, depth int) {
+		ordered = append(ordered, process)
+		depths = append(depths, depth)
+		for _, child := range children[process.Pid] {
+			walk(child, depth+1)
+		}
+	}
+	for _, process := range processList {
+		//the parent may be outside the container or filtered out
+		if !pids[process.PPid] {
+			walk(process, 0)
+		}
+	}
+	return ordered, depths
 }
 
 func GetSelection(message string, options []string) int {
@@ -0,0 +1,122 @@
+package target
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/ncsnw/skavo/pkg/k8s"
+	"github.com/ncsnw/skavo/pkg/util"
+)
+
+//A container run by the docker or podman cli
+type Docker struct {
+	//The cli to use, docker or podman
+	Cli       string
+	Container string
+}
+
+func (d *Docker) Exec(command []string, options ...k8s.ExecOptions) error {
+	opts := execOpts(options)
+	args := []string{"exec"}
+	if opts.In != nil {
+		args = append(args, "-i")
+	}
+	args = append(append(args, d.Container), command...)
+	cmd := exec.Command(d.Cli, args...)
+	cmd.Stdin = opts.In
+	cmd.Stdout = opts.Out
+	cmd.Stderr = opts.ErrOut
+	return cmd.Run()
+}
+
+func (d *Docker) CopyTo(srcPath string, destPath string) {
+	cmd := exec.Command(d.Cli, "cp", srcPath, d.Container+":"+destPath)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	util.MaybePanic(cmd.Run())
+}
+
+func (d *Docker) CopyFrom(srcPath string, destPath string) {
+	cmd := exec.Command(d.Cli, "cp", d.Container+":"+srcPath, destPath)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	util.MaybePanic(cmd.Run())
+}
+
+//Forwards through the container's loopback, where delve listens, by relaying each connection over docker exec
+func (d *Docker) ForwardPort(address string, localPort string, targetPort string) (chan struct{}, string) {
+	return proxy(address, localPort, func() (io.ReadWriteCloser, error) {
+		return d.dialLoopback(targetPort)
+	})
+}
+
+//relays stdin and stdout to a port on the container's loopback, with whichever tool the image has
+const loopbackRelay = `
+if command -v nc >/dev/null 2>&1; then
+	exec nc 127.0.0.1 $1
+elif command -v socat >/dev/null 2>&1; then
+	exec socat - TCP:127.0.0.1:$1
+else
+	echo "the container needs nc or socat to forward the delve port" >&2
+	exit 1
+fi
+`
+
+func (d *Docker) dialLoopback(port string) (io.ReadWriteCloser, error) {
+	cmd := exec.Command(d.Cli, "exec", "-i", d.Container, "sh", "-c", loopbackRelay, "relay", port)
+	cmd.Stderr = os.Stderr
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to exec into container %s: %+v", d.Container, err)
+	}
+	return &cmdConn{cmd: cmd, in: in, out: out}, nil
+}
+
+//A connection over a command's stdin and stdout
+type cmdConn struct {
+	cmd *exec.Cmd
+	in  io.WriteCloser
+	out io.ReadCloser
+}
+
+func (c *cmdConn) Read(p []byte) (int, error) {
+	return c.out.Read(p)
+}
+
+func (c *cmdConn) Write(p []byte) (int, error) {
+	return c.in.Write(p)
+}
+
+func (c *cmdConn) Close() error {
+	_ = c.in.Close()
+	_ = c.cmd.Process.Kill()
+	_ = c.cmd.Wait()
+	return nil
+}
+
+func (d *Docker) String() string {
+	return fmt.Sprintf("%s container %s", d.Cli, d.Container)
+}
+
+//List the names of the running containers
+func ListContainers(cli string) []string {
+	out := new(bytes.Buffer)
+	cmd := exec.Command(cli, "ps", "--format", "{{.Names}}")
+	cmd.Stdout = out
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		panic(fmt.Errorf("failed to list %s containers: %+v", cli, err))
+	}
+	return strings.Fields(out.String())
+}
@@ -0,0 +1,52 @@
+package target
+
+import (
+	"io"
+	"net"
+	"os"
+	"os/exec"
+
+	"github.com/ncsnw/skavo/pkg/k8s"
+	"github.com/ncsnw/skavo/pkg/util"
+)
+
+//This machine
+type Local struct{}
+
+func (l *Local) Exec(command []string, options ...k8s.ExecOptions) error {
+	opts := execOpts(options)
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Stdin = opts.In
+	cmd.Stdout = opts.Out
+	cmd.Stderr = opts.ErrOut
+	return cmd.Run()
+}
+
+func (l *Local) CopyTo(srcPath string, destPath string) {
+	cmd := exec.Command("cp", "-R", srcPath, destPath)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	util.MaybePanic(cmd.Run())
+}
+
+func (l *Local) CopyFrom(srcPath string, destPath string) {
+	//copy what links point to, like /proc/<pid>/exe
+	cmd := exec.Command("cp", "-RL", srcPath, destPath)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	util.MaybePanic(cmd.Run())
+}
+
+//delve listens on this machine already, so only proxy when the ports differ
+func (l *Local) ForwardPort(address string, localPort string, targetPort string) (chan struct{}, string) {
+	if localPort == targetPort && address == "127.0.0.1" {
+		return make(chan struct{}), localPort
+	}
+	return proxy(address, localPort, func() (io.ReadWriteCloser, error) {
+		return net.Dial("tcp", net.JoinHostPort("127.0.0.1", targetPort))
+	})
+}
+
+func (l *Local) String() string {
+	return "localhost"
+}
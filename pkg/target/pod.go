@@ -0,0 +1,35 @@
+package target
+
+import (
+	"fmt"
+
+	"github.com/ncsnw/skavo/pkg/k8s"
+)
+
+//A container in a kubernetes pod
+type Pod struct {
+	Client        *k8s.Client
+	Namespace     string
+	PodName       string
+	ContainerName string
+}
+
+func (p *Pod) Exec(command []string, options ...k8s.ExecOptions) error {
+	return p.Client.Exec(p.PodName, p.Namespace, p.ContainerName, command, options...)
+}
+
+func (p *Pod) CopyTo(srcPath string, destPath string) {
+	p.Client.CopyToPod(p.Namespace, p.PodName, p.ContainerName, srcPath, destPath)
+}
+
+func (p *Pod) CopyFrom(srcPath string, destPath string) {
+	p.Client.CopyFromPod(p.Namespace, p.PodName, p.ContainerName, srcPath, destPath)
+}
+
+func (p *Pod) ForwardPort(address string, localPort string, targetPort string) (chan struct{}, string) {
+	return p.Client.ForwardPort(p.Namespace, p.PodName, address, localPort, targetPort)
+}
+
+func (p *Pod) String() string {
+	return fmt.Sprintf("pod %s/%s container %s", p.Namespace, p.PodName, p.ContainerName)
+}
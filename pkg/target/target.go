@@ -0,0 +1,76 @@
+package target
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+
+	"github.com/ncsnw/skavo/pkg/k8s"
+)
+
+//A Target is somewhere a go process can be debugged, like a container in a pod, a docker container or this machine
+type Target interface {
+	//Execute a command in the target
+	Exec(command []string, options ...k8s.ExecOptions) error
+	//Copy a local file or directory to destPath in the target
+	CopyTo(srcPath string, destPath string)
+	//Copy a file in the target to destPath on this machine
+	CopyFrom(srcPath string, destPath string)
+	//Expose the target port on the local port on address, returns once the port is ready with the local port, which is
+	//picked when localPort is 0. Close the returned channel to stop forwarding.
+	ForwardPort(address string, localPort string, targetPort string) (chan struct{}, string)
+	//A short description of the target for display
+	String() string
+}
+
+func execOpts(options []k8s.ExecOptions) k8s.ExecOptions {
+	if len(options) > 0 {
+		return options[0]
+	}
+	return k8s.ExecOptions{}
+}
+
+//listen on the local port and pipe each connection to the connection returned by dial
+func proxy(address string, localPort string, dial func() (io.ReadWriteCloser, error)) (chan struct{}, string) {
+	listener, err := net.Listen("tcp", net.JoinHostPort(address, localPort))
+	if err != nil {
+		panic(fmt.Errorf("failed to listen on local port %s: %+v", localPort, err))
+	}
+	fmt.Println("Ports forwarded!...")
+	stopChan := make(chan struct{})
+	go func() {
+		<-stopChan
+		_ = listener.Close()
+	}()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				select {
+				case <-stopChan:
+					return
+				default:
+					panic(fmt.Errorf("failed to accept connection: %+v", err))
+				}
+			}
+			go pipe(conn, dial)
+		}
+	}()
+	return stopChan, strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)
+}
+
+func pipe(conn net.Conn, dial func() (io.ReadWriteCloser, error)) {
+	defer conn.Close()
+	remote, err := dial()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to connect to target: %+v\n", err)
+		return
+	}
+	defer remote.Close()
+	go func() {
+		_, _ = io.Copy(remote, conn)
+	}()
+	_, _ = io.Copy(conn, remote)
+}
@@ -0,0 +1,8 @@
+package util
+
+import "strings"
+
+//Quote a string for use as a single word in a sh command
+func ShellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "'\"'\"'") + "'"
+}
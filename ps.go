@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/mattn/go-isatty"
+
+	"github.com/ncsnw/skavo/pkg/agent"
+	"github.com/ncsnw/skavo/pkg/discover"
+	"github.com/ncsnw/skavo/pkg/k8s"
+	"github.com/ncsnw/skavo/pkg/prompt"
+	"github.com/ncsnw/skavo/pkg/target"
+)
+
+//skavo ps [regex]: find processes in every running container of the listed pods, and attach to one
+func ps() {
+	client := k8s.NewK8sClient(*kubeContext, kubeconfig)
+	pattern := *processFilter
+	if len(args) > 0 {
+		pattern = args[0]
+	}
+	var filter *regexp.Regexp
+	if pattern != "" {
+		var err error
+		if filter, err = regexp.Compile(pattern); err != nil {
+			panic(fmt.Errorf("invalid process regex %s: %+v", pattern, err))
+		}
+	}
+	pods := listPods(client)
+	fmt.Printf("Searching %d pods\n", len(pods))
+	matches, failures := discover.Find(client, pods, filter, *concurrency)
+	for _, failure := range failures {
+		fmt.Fprintf(os.Stderr, "Couldn't search %s\n", failure)
+	}
+	if !isTerminal(os.Stdout) {
+		prompt.PrintMatches(os.Stdout, matches)
+		return
+	}
+	match := prompt.SelectMatch(matches)
+	t := &target.Pod{
+		Client:        client,
+		Namespace:     match.Pod.Namespace,
+		PodName:       match.Pod.Name,
+		ContainerName: match.Container,
+	}
+	start(newPodDelve(t, agent.New(t), match.Process, client, match.Pod, match.Container), match.Pod)
+}
+
+func isTerminal(f *os.File) bool {
+	return isatty.IsTerminal(f.Fd())
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"github.com/ncsnw/skavo/pkg/agent"
+	"github.com/ncsnw/skavo/pkg/prompt"
+)
+
+//skavo script <file.star>: run a starlark script against the process with delve's scripting support
+func script() {
+	if len(args) != 1 {
+		panic("usage: skavo script [flags] <script.star>")
+	}
+	t, client, pod := selectTarget()
+	skavoAgent := agent.New(t)
+	defer skavoAgent.Close()
+	process := prompt.SelectProcess(skavoAgent.ListProcesses(), *processFilter)
+	pd := newPodDelve(t, skavoAgent, process, client, pod, *containerName)
+	//breakpoints the script sets aren't the project's
+	pd.BreakpointsFile = ""
+	pd.RunScript(args[0])
+}
@@ -4,73 +4,333 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"os"
+	"os/exec"
 	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
 
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/util/homedir"
 
+	"github.com/ncsnw/skavo/pkg/agent"
+	"github.com/ncsnw/skavo/pkg/config"
 	"github.com/ncsnw/skavo/pkg/delve"
+	"github.com/ncsnw/skavo/pkg/discover"
 	"github.com/ncsnw/skavo/pkg/k8s"
 	"github.com/ncsnw/skavo/pkg/prompt"
+	"github.com/ncsnw/skavo/pkg/target"
 )
 
+var (
+	kubeconfig    = flag.String("kubeconfig", filepath.Join(homedir.HomeDir(), ".kube", "config"), "(optional) absolute path to the kubeconfig file")
+	kubeContext   = flag.String("context", "", "The kube config context to use")
+	workload      = flag.String("target", "", "Pick a pod of this workload instead of prompting, like deploy/api, sts/db or job/migrate")
+	podName       = flag.String("pod", "", "Specify the pod instead of prompting")
+	containerName = flag.String("container", "", "Specify the container instead of prompting")
+	processFilter = flag.String("process", "", "Filter the list of processes in a container")
+	namespace     = flag.String("namespace", "", "Specify the namespace instead of using the kubeconfig context's namespace or prompting. Use namespace \"ALL\" to view all namespaces")
+	waitFor       = flag.String("wait-for-process", "", "Wait for a process matching this regex to start and attach to it right away")
+	isRestart     = flag.Bool("restart", false, "Restart the process using delve instead of attaching to the existing process.")
+	isRelaunch    = flag.Bool("relaunch", false, "Relaunch the pod with delve exec. Warning: this will restart all pods under the parent resource (ReplicaSet, Deployment, etc)")
+	localPort     = flag.String("localport", "34455", "Specify the host machine port to forward to the pod port, 0 or auto picks a free one")
+	address       = flag.String("address", "127.0.0.1", "The address to bind the local port on, delve has no authentication so be careful binding anything but loopback")
+	podPort       = flag.String("podport", "55443", "Specify the pod port for delve to listen on, or random to pick a free one")
+	selector      = flag.String("selector", "", "Only list pods matching this label selector")
+	fieldSelector = flag.String("field-selector", "", "Only list pods matching this field selector")
+	node          = flag.String("node", "", "Only list pods running on this node")
+	allPods       = flag.Bool("all-pods", false, "Include pods that aren't running in the pod list")
+	onExit        = flag.String("on-exit", delve.OnExitDetach, "What to do with the process when skavo exits: detach, continue (leave delve attached) or kill")
+	cleanup       = flag.Bool("cleanup", false, "Remove the tooling skavo installed in the container when skavo exits")
+	delveVersion  = flag.String("delve-version", "", "Install this delve release instead of one that supports the process's go version, e.g. v1.7.3")
+	dlvFlags      = stringsVar("dlvflags", "An extra flag to pass to dlv when attaching or restarting, can be given more than once")
+	profileName   = flag.String("p", "", "Use the named profile from .skavo.yaml or ~/.config/skavo/config.yaml")
+	backend       = flag.String("backend", "k8s", "Where the process runs: k8s, docker, podman or local. Use -container to specify the docker/podman container")
+	concurrency   = flag.Int("concurrency", 10, "How many containers skavo ps searches at once")
+	dumpDir       = flag.String("dump-dir", ".", "The directory skavo dump copies the core and executable to")
+	snapshotOut   = flag.String("out", "", "The file skavo goroutines or skavo catch writes its json to, default goroutines.<pid>.json or crash.<pid>.json")
+	onCrash       = flag.String("on-crash", delve.OnCrashCrash, "What skavo catch does once the crash is captured: crash (let it crash with delve attached) or detach")
+	interval      = flag.Duration("interval", 10*time.Second, "How long skavo analyze waits between its two samples, 0 takes one")
+	breakpoints   = flag.String("breakpoints", "", "The breakpoints file to set breakpoints from and save them to on exit, default .skavo/breakpoints.yaml in the project, none to not keep breakpoints")
+	traceAt       = stringsVar("at", "A location for skavo trace to trace, like file.go:123 or a function, can be given more than once")
+	traceExprs    = stringsVar("expr", "An expression for skavo trace to print at each hit, can be given more than once")
+	traceFunc     = flag.String("func", "", "Trace the calls and returns of the functions matching this regex with skavo trace")
+	traceOut      = flag.String("trace-out", "", "Write the skavo trace hits to this file as json lines instead of printing them")
+	goroutineID   = flag.Int("goroutine", -1, "The goroutine skavo eval evaluates in, -1 for the one that was running when the process stopped")
+	frame         = flag.Int("frame", 0, "The stack frame of the goroutine skavo eval evaluates in")
+	evalJSON      = flag.Bool("json", false, "Print the skavo eval results as json")
+	locals        = flag.Bool("locals", false, "Include the arguments and locals of every frame in the skavo goroutines snapshot")
+)
+
+//A flag that can be given more than once
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringsFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+func stringsVar(name string, usage string) *[]string {
+	var values []string
+	flag.Var((*stringsFlag)(&values), name, usage)
+	return &values
+}
+
+//The profile given with -p, empty if there isn't one
+var profile = &config.Profile{}
+
+//The arguments that aren't flags
+var args []string
+
+//Parse the flags wherever they are among the arguments, like skavo eval 'expr' -goroutine 1, and return the other
+//arguments. Arguments after -- are never flags.
+func parseArgs(arguments []string) []string {
+	positional := make([]string, 0)
+	for {
+		_ = flag.CommandLine.Parse(arguments)
+		rest := flag.Args()
+		if len(rest) == 0 {
+			return positional
+		}
+		if len(arguments) > len(rest) && arguments[len(arguments)-len(rest)-1] == "--" {
+			return append(positional, rest...)
+		}
+		positional = append(positional, rest[0])
+		arguments = rest[1:]
+	}
+}
+
+//The subcommands, skavo attaches to a process when there isn't one
+var commands = map[string]func(){
+	"ps":         ps,
+	"dump":       dump,
+	"cp":         cp,
+	"goroutines": snapshot,
+	"snapshot":   snapshot,
+	"analyze":    analyze,
+	"trace":      trace,
+	"eval":       eval,
+	"script":     script,
+	"catch":      catch,
+	"watch":      watch,
+}
+
 func main() {
+	command := ""
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		command = os.Args[1]
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+	}
+	args = parseArgs(os.Args[1:])
 
-	kubeconfig := flag.String("kubeconfig", filepath.Join(homedir.HomeDir(), ".kube", "config"), "(optional) absolute path to the kubeconfig file")
-	kubeContext := flag.String("context", "", "The kube config context to use")
-	podName := flag.String("pod", "", "Specify the pod instead of prompting")
-	containerName := flag.String("container", "", "Specify the container instead of prompting")
-	processFilter := flag.String("process", "", "Filter the list of processes in a container")
-	namespace := flag.String("namespace", "default", "Specify the namespace instead of using default. Use namespace \"ALL\" to view all namespaces")
-	isRestart := flag.Bool("restart", false, "Restart the process using delve instead of attaching to the existing process.")
-	isRelaunch := flag.Bool("relaunch", false, "Relaunch the pod with delve exec. Warning: this will restart all pods under the parent resource (ReplicaSet, Deployment, etc)")
-	localPort := flag.String("localport", "34455", "Specify the host machine port to forward to the pod port")
-	podPort := flag.String("podport", "55443", "Specify the pod port for delve to listen on")
-	flag.Parse()
-
-	client := k8s.NewK8sClient(*kubeContext, kubeconfig)
-	ns := *namespace
-	if ns == "ALL" {
-		ns = ""
+	if *profileName != "" {
+		profile = config.Load().Profile(*profileName)
+		profile.Apply(flag.CommandLine)
 	}
-	var pod *v1.Pod
-	if *podName == "" {
-		podList := client.ListPods(ns)
 
-		pod = prompt.SelectPod(podList.Items)
-		fmt.Printf("Selected pod: %s\n", pod.Name)
-	} else {
-		var err error
-		pod, err = client.CoreClient.Pods(ns).Get(context.TODO(), *podName, metav1.GetOptions{})
+	if command == "" {
+		debug()
+		return
+	}
+	run, ok := commands[command]
+	if !ok {
+		panic(fmt.Errorf("unknown command %s", command))
+	}
+	run()
+}
+
+//Walk through choosing a process and debug it
+func debug() {
+	t, client, pod := selectTarget()
+	skavoAgent := agent.New(t)
+	if *waitFor != "" {
+		filter, err := regexp.Compile(*waitFor)
 		if err != nil {
-			panic(fmt.Errorf("failed to get pod: %s. %+v", *podName, err))
+			panic(fmt.Errorf("invalid process regex %s: %+v", *waitFor, err))
 		}
+		pd := newPodDelve(t, skavoAgent, k8s.ContainerProcess{}, client, pod, *containerName)
+		pd.WaitForProcess(func(process k8s.ContainerProcess) bool {
+			return discover.Matches(process, filter)
+		})
+		start(pd, pod)
+		return
 	}
-	if *containerName == "" {
-		container := prompt.SelectContainer(pod.Spec.Containers)
-		containerName = &container.Name
-		fmt.Printf("Selected container: %s\n", container.Name)
+	process := prompt.SelectProcess(skavoAgent.ListProcesses(), *processFilter)
+	start(newPodDelve(t, skavoAgent, process, client, pod, *containerName), pod)
+}
+
+//Choose the target with the backend's prompts, the client and pod are nil for other backends than k8s
+func selectTarget() (target.Target, *k8s.Client, *v1.Pod) {
+	var t target.Target
+	var client *k8s.Client
+	var pod *v1.Pod
+	switch *backend {
+	case "k8s":
+		client = k8s.NewK8sClient(*kubeContext, kubeconfig)
+		if *podName == "" && *workload != "" {
+			//a workload with one pod needs no prompt, so scripts can use -target
+			pods := workloadPods(client)
+			if len(pods) == 1 {
+				pod = &pods[0]
+			} else {
+				pod = prompt.SelectPod(pods)
+			}
+			fmt.Printf("Selected pod: %s\n", pod.Name)
+		} else if *podName == "" {
+			pod = prompt.SelectPod(listPods(client))
+			fmt.Printf("Selected pod: %s\n", pod.Name)
+		} else if *namespace != "" && *namespace != prompt.AllNamespaces {
+			var err error
+			pod, err = client.CoreClient.Pods(*namespace).Get(context.TODO(), *podName, metav1.GetOptions{})
+			if err != nil {
+				panic(fmt.Errorf("failed to get pod: %s. %+v", *podName, err))
+			}
+		} else {
+			pods := client.FindPods(*podName)
+			if len(pods) < 1 {
+				panic(fmt.Errorf("pod %s not found in any namespace", *podName))
+			}
+			pod = prompt.SelectPod(pods)
+			fmt.Printf("Selected pod: %s/%s\n", pod.Namespace, pod.Name)
+		}
+		if *containerName == "" {
+			container := prompt.SelectContainer(pod.Spec.Containers)
+			containerName = &container.Name
+			fmt.Printf("Selected container: %s\n", container.Name)
+		}
+		if !k8s.ContainersRunning(pod, *containerName) {
+			panic(fmt.Errorf("container %s in pod %s isn't running, it may be crashing or still starting", *containerName, pod.Name))
+		}
+		t = &target.Pod{
+			Client:        client,
+			Namespace:     pod.Namespace,
+			PodName:       pod.Name,
+			ContainerName: *containerName,
+		}
+	case "docker", "podman":
+		if *containerName == "" {
+			container := prompt.SelectContainerName(target.ListContainers(*backend))
+			containerName = &container
+			fmt.Printf("Selected container: %s\n", container)
+		}
+		t = &target.Docker{Cli: *backend, Container: *containerName}
+	case "local":
+		//the agent lists processes from /proc and runs dlv with linux's ptrace
+		if runtime.GOOS != "linux" {
+			panic(fmt.Errorf("-backend local only works on linux, this is %s. Run the process in docker and use -backend docker", runtime.GOOS))
+		}
+		if _, err := exec.LookPath("dlv"); err != nil {
+			panic("dlv not found on PATH, install it with: go install github.com/go-delve/delve/cmd/dlv@latest")
+		}
+		t = &target.Local{}
+	default:
+		panic(fmt.Errorf("unknown backend: %s", *backend))
 	}
+	return t, client, pod
+}
 
-	processes := client.ListProcesses(pod, *containerName)
+//List the pods in the namespace matching the selectors, prompting for the namespace if there isn't one
+func listPods(client *k8s.Client) []v1.Pod {
+	ns := *namespace
+	if ns == "" {
+		ns = client.Namespace
+	}
+	if ns == "" {
+		ns = prompt.SelectNamespace(client.ListNamespaces())
+	}
+	if ns == prompt.AllNamespaces {
+		ns = ""
+	}
+	fields := *fieldSelector
+	if *node != "" {
+		fields = strings.Trim(fields+",spec.nodeName="+*node, ",")
+	}
+	pods := client.ListPods(ns, metav1.ListOptions{LabelSelector: *selector, FieldSelector: fields}).Items
+	if !*allPods {
+		pods = k8s.RunningPods(pods, *containerName)
+	}
+	return pods
+}
 
-	process := prompt.SelectProcess(processes, *processFilter)
-	pd := delve.PodDelve{
-		Namespace:     pod.Namespace,
-		PodName:       pod.Name,
-		ContainerName: *containerName,
+//List the pods of the -target workload
+func workloadPods(client *k8s.Client) []v1.Pod {
+	pods, err := client.WorkloadPods(workloadNamespace(client), *workload)
+	if err != nil {
+		panic(fmt.Errorf("failed to list the pods of %s: %+v", *workload, err))
+	}
+	if !*allPods {
+		pods = k8s.RunningPods(pods, *containerName)
+	}
+	return pods
+}
+
+//The namespace of the -target workload, the kubeconfig context's if there's no -namespace
+func workloadNamespace(client *k8s.Client) string {
+	ns := *namespace
+	if ns == "" {
+		ns = client.Namespace
+	}
+	if ns == "" {
+		ns = "default"
+	}
+	if ns == prompt.AllNamespaces {
+		panic("-target needs a namespace")
+	}
+	return ns
+}
+
+func newPodDelve(t target.Target, skavoAgent *agent.Agent, process k8s.ContainerProcess, client *k8s.Client, pod *v1.Pod, container string) *delve.PodDelve {
+	pd := &delve.PodDelve{
+		ContainerName: container,
 		Process:       process,
 		Client:        client,
+		Target:        t,
+		Agent:         skavoAgent,
+		Address:       *address,
 		LocalPort:     *localPort,
 		PodPort:       *podPort,
+		DlvFlags:      *dlvFlags,
+		DelveVersion:  *delveVersion,
+		OnExit:        *onExit,
+		Cleanup:       *cleanup,
 	}
+	switch *breakpoints {
+	case "":
+		pd.BreakpointsFile = config.BreakpointsPath()
+	case "none":
+	default:
+		pd.BreakpointsFile = *breakpoints
+	}
+	if pod != nil {
+		pd.Namespace = pod.Namespace
+		pd.PodName = pod.Name
+	}
+	return pd
+}
+
+//Start delve in the mode the flags ask for and forward the port until skavo exits
+func start(pd *delve.PodDelve, pod *v1.Pod) {
+	switch *onExit {
+	case delve.OnExitDetach, delve.OnExitContinue, delve.OnExitKill:
+	default:
+		panic(fmt.Errorf("unknown -on-exit %s, expected detach, continue or kill", *onExit))
+	}
+	profile.PrintSourceMappings()
 	if *isRestart {
 		pd.RestartProcess()
 	} else if *isRelaunch {
+		if pod == nil {
+			panic("relaunch is only supported by the k8s backend")
+		}
 		pd.Relaunch(pod)
 	} else {
 		pd.AttachToProcess()
 	}
-
 }
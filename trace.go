@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ncsnw/skavo/pkg/agent"
+	"github.com/ncsnw/skavo/pkg/delve"
+	"github.com/ncsnw/skavo/pkg/prompt"
+)
+
+//skavo trace -at file.go:123 -expr req.ID: print the expressions each time the line runs, without stopping the process.
+//skavo trace -func regex: print the calls and returns of the matching functions, then how many times each was called.
+func trace() {
+	usage := "usage: skavo trace -at file.go:123 [-expr expression]... or skavo trace -func regex"
+	if len(*traceAt) == 0 && *traceFunc == "" {
+		panic(usage)
+	}
+	if len(*traceAt) > 0 && *traceFunc != "" {
+		panic("-at and -func can't be used together, " + usage)
+	}
+	if len(*traceExprs) > 0 && *traceFunc != "" {
+		panic("-expr only applies to -at, -func prints the arguments and return values")
+	}
+	write := printHit
+	if *traceOut != "" {
+		f, err := os.Create(*traceOut)
+		if err != nil {
+			panic(fmt.Errorf("failed to create %s: %+v", *traceOut, err))
+		}
+		defer f.Close()
+		encoder := json.NewEncoder(f)
+		write = func(hit delve.TraceHit) {
+			if err := encoder.Encode(hit); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to write to %s: %+v\n", *traceOut, err)
+			}
+		}
+	}
+	t, client, pod := selectTarget()
+	skavoAgent := agent.New(t)
+	defer skavoAgent.Close()
+	process := prompt.SelectProcess(skavoAgent.ListProcesses(), *processFilter)
+	pd := newPodDelve(t, skavoAgent, process, client, pod, *containerName)
+	if *traceFunc == "" {
+		pd.Trace(*traceAt, *traceExprs, write)
+		return
+	}
+	calls := make(map[string]int)
+	pd.TraceFunctions(*traceFunc, func(hit delve.TraceHit) {
+		if !hit.Return {
+			calls[hit.Function]++
+		}
+		write(hit)
+	})
+	printCalls(calls)
+}
+
+func printHit(hit delve.TraceHit) {
+	at := fmt.Sprintf("%s goroutine %d", hit.Time.Format("15:04:05.000"), hit.Goroutine)
+	switch {
+	case hit.Return:
+		fmt.Printf("%s < %s => (%s)\n", at, hit.Function, joinValues(hit.ReturnValues, ", "))
+	case hit.Arguments != nil && hit.Values == nil:
+		fmt.Printf("%s > %s(%s) %s:%d\n", at, hit.Function, joinValues(hit.Arguments, ", "), filepath.Base(hit.File), hit.Line)
+	default:
+		fmt.Printf("%s %s:%d %s %s\n", at, filepath.Base(hit.File), hit.Line, hit.Function, joinValues(hit.Values, " "))
+	}
+}
+
+func joinValues(values []delve.TraceValue, sep string) string {
+	joined := make([]string, 0, len(values))
+	for _, v := range values {
+		joined = append(joined, v.Expr+"="+v.Value)
+	}
+	return strings.Join(joined, sep)
+}
+
+//How many times each function was called, the most called first
+func printCalls(calls map[string]int) {
+	functions := make([]string, 0, len(calls))
+	for function := range calls {
+		functions = append(functions, function)
+	}
+	sort.Slice(functions, func(i, j int) bool {
+		if calls[functions[i]] != calls[functions[j]] {
+			return calls[functions[i]] > calls[functions[j]]
+		}
+		return functions[i] < functions[j]
+	})
+	fmt.Printf("\n%d functions called\n", len(functions))
+	for _, function := range functions {
+		fmt.Printf("%8d %s\n", calls[function], function)
+	}
+}
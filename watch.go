@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"regexp"
+	"syscall"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/ncsnw/skavo/pkg/agent"
+	"github.com/ncsnw/skavo/pkg/delve"
+	"github.com/ncsnw/skavo/pkg/discover"
+	"github.com/ncsnw/skavo/pkg/k8s"
+	"github.com/ncsnw/skavo/pkg/prompt"
+	"github.com/ncsnw/skavo/pkg/target"
+)
+
+//How long a new pod's process has to start before skavo gives up on the pod
+const watchProcessTimeout = time.Minute
+
+//The running pods an informer reports at once, like every pod at the start, are collected this long to pick the newest
+const watchSettle = time.Second
+
+//skavo watch <kind/name>: debug the newest running pod of the workload, and move the session and port forward to each
+//new pod that starts running, like after a rollout or a crash-restart
+func watch() {
+	if len(args) > 1 {
+		panic("usage: skavo watch [flags] <kind/name>")
+	}
+	if len(args) == 1 {
+		*workload = args[0]
+	}
+	if *workload == "" {
+		panic("usage: skavo watch [flags] <kind/name>, like skavo watch deploy/api")
+	}
+	if *backend != "k8s" {
+		panic("skavo watch is only supported by the k8s backend")
+	}
+	client := k8s.NewK8sClient(*kubeContext, kubeconfig)
+	ns := workloadNamespace(client)
+	options, err := client.WorkloadSelector(ns, *workload)
+	if err != nil {
+		panic(fmt.Errorf("failed to find %s: %+v", *workload, err))
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	//without -container every container is watched until one is picked, newest skips the restarts of the others
+	running := client.WatchRunningPods(ns, options, *containerName, stop)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	w := &watcher{client: client}
+	fmt.Printf("Watching the pods of %s in %s, press ctrl-c to stop\n", *workload, ns)
+	for {
+		select {
+		case <-signals:
+			//a second ctrl-c exits right away
+			signal.Stop(signals)
+			fmt.Println("Exiting...")
+			w.stop()
+			return
+		case pod := <-running:
+			pod = newest(pod, running, w.current, w.container)
+			if pod == nil {
+				continue
+			}
+			fmt.Printf("Pod %s is running\n", pod.Name)
+			w.stop()
+			w.attach(pod)
+		}
+	}
+}
+
+//Wait for the other pods that are reported at the same time and return the newest, nil if none is newer than current.
+//The current pod counts as newer when the debugged container restarted, a sidecar restarting doesn't count.
+func newest(pod *v1.Pod, running chan *v1.Pod, current *v1.Pod, container string) *v1.Pod {
+	settle := time.After(watchSettle)
+	for {
+		select {
+		case other := <-running:
+			//a pod reported twice restarted in between
+			if other.UID == pod.UID || other.CreationTimestamp.After(pod.CreationTimestamp.Time) {
+				pod = other
+			}
+		case <-settle:
+			if current == nil {
+				return pod
+			}
+			if pod.UID == current.UID {
+				if k8s.RunningKey(pod, container) == k8s.RunningKey(current, container) {
+					return nil
+				}
+				return pod
+			}
+			if !pod.CreationTimestamp.After(current.CreationTimestamp.Time) {
+				return nil
+			}
+			return pod
+		}
+	}
+}
+
+//The debugging session that follows the workload's pods, the container and process are picked for the first pod
+type watcher struct {
+	client      *k8s.Client
+	current     *v1.Pod
+	pd          *delve.PodDelve
+	stopForward chan struct{}
+	container   string
+	//The executable of the process picked in the first pod, it's looked for in the next ones
+	exe string
+	//Keep the local port, so the IDE can reconnect to the same one
+	localPort string
+}
+
+func (w *watcher) stop() {
+	if w.pd == nil {
+		return
+	}
+	fmt.Printf("Stopping the session in %s\n", w.current.Name)
+	w.pd.Stop(w.stopForward)
+	w.pd = nil
+}
+
+//Debug the process in the pod, a pod that can't be debugged is skipped until the next one starts
+func (w *watcher) attach(pod *v1.Pod) {
+	w.current = pod
+	var skavoAgent *agent.Agent
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Fprintf(os.Stderr, "Couldn't debug %s, waiting for the next pod: %v\n", pod.Name, r)
+			if skavoAgent != nil {
+				skavoAgent.Close()
+			}
+			w.pd = nil
+		}
+	}()
+	if w.container == "" {
+		w.container = *containerName
+	}
+	if w.container == "" {
+		w.container = prompt.SelectContainer(pod.Spec.Containers).Name
+		fmt.Printf("Selected container: %s\n", w.container)
+	}
+	t := &target.Pod{Client: w.client, Namespace: pod.Namespace, PodName: pod.Name, ContainerName: w.container}
+	skavoAgent = agent.New(t)
+	process := w.process(skavoAgent)
+	pd := newPodDelve(t, skavoAgent, process, w.client, pod, w.container)
+	if w.localPort != "" {
+		pd.LocalPort = w.localPort
+	}
+	w.stopForward = pd.Attach()
+	w.pd = pd
+	w.localPort = pd.LocalPort
+}
+
+//Pick the process in the first pod, then wait for the same executable, or a process matching -process, in the next
+func (w *watcher) process(skavoAgent *agent.Agent) k8s.ContainerProcess {
+	if w.exe == "" {
+		process := prompt.SelectProcess(skavoAgent.ListProcesses(), *processFilter)
+		w.exe = process.Exe
+		return process
+	}
+	var filter *regexp.Regexp
+	if *processFilter != "" {
+		filter = regexp.MustCompile(*processFilter)
+	}
+	deadline := time.Now().Add(watchProcessTimeout)
+	for {
+		for _, process := range skavoAgent.ListProcesses() {
+			if process.Exe == w.exe || filter != nil && discover.Matches(process, filter) {
+				fmt.Printf("Found pid %d: %v\n", process.Pid, process.Command)
+				return process
+			}
+		}
+		if time.Now().After(deadline) {
+			panic(fmt.Errorf("%s didn't start within %s", w.exe, watchProcessTimeout))
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}